@@ -2,19 +2,31 @@ package rsslap
 
 import (
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"hash"
+	"hash/fnv"
 	"math/rand"
+	"os"
+	"strings"
+	"sync/atomic"
 	"time"
 
-	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
 )
 
 const (
-	RecordPeriod = 1 * time.Second
+	RecordPeriod    = 1 * time.Second
+	AbandonMinDelay = 1 * time.Millisecond
+	// Added to the seed of the agent, not to share the source of '--abandon-rate' with the think-time or the data
+	AbandonSeedOffset = 2 << 32
 )
 
+// Error of the query canceled by '--query-timeout'
+var errQueryTimeout = errors.New("query timeout")
+
 type Agent struct {
 	id       int
 	rsConfig *RsConfig
@@ -22,6 +34,33 @@ type Agent struct {
 	taskOps  *TaskOpts
 	dataOpts *DataOpts
 	data     *Data
+	deferred bool
+	// Time to establish the last connection, excluding the pre-queries
+	connectTime time.Duration
+	hasher      hash.Hash64
+	checksum    uint64
+	rowCnt      int64
+	// for '--abandon-rate'
+	lastResTime time.Duration
+	abandonCnt  int
+	// for '--agent-result-mem-limit'
+	resultBuf         [][]byte
+	resultMemLimitCnt int
+	// for '--max-retries'
+	retryCnt int
+	// for '--pool-size'
+	pool     *connPool
+	poolConn *pgxpool.Conn
+	// for '--global-rate'
+	globalLimiter *globalRateLimiter
+	// for '--schedule'
+	phaseData []*Data
+	// for '--arrival poisson'
+	arrivalRnd *rand.Rand
+	// for '--delay-distribution' and '--statement-interval'
+	thinkRnd *rand.Rand
+	// for '--abandon-rate'
+	abandonRnd *rand.Rand
 }
 
 func newAgent(id int, pgCfg *RsConfig, taskOps *TaskOpts, dataOpts *DataOpts) (agent *Agent) {
@@ -30,13 +69,69 @@ func newAgent(id int, pgCfg *RsConfig, taskOps *TaskOpts, dataOpts *DataOpts) (a
 		rsConfig: pgCfg,
 		taskOps:  taskOps,
 		dataOpts: dataOpts,
+		hasher:   fnv.New64a(),
 	}
 
 	return
 }
 
 func (agent *Agent) prepare(idList []string) error {
-	conn, err := agent.rsConfig.openAndPing()
+	var newIdList []string
+
+	// NOTE: Split the rows between the agents so that a row is not deleted twice
+	if agent.dataOpts.LoadType == LoadTypeDelete && !agent.taskOps.DBPerAgent {
+		for i := agent.id; i < len(idList); i += agent.taskOps.NAgents {
+			newIdList = append(newIdList, idList[i])
+		}
+	} else {
+		newIdList = make([]string, len(idList))
+		copy(newIdList, idList)
+	}
+
+	agent.data = newData(agent.dataOpts, newIdList, agent.dataOpts.Seed+int64(agent.id))
+	rand.New(agent.data.randSrc).Shuffle(len(newIdList), func(i, j int) { newIdList[i], newIdList[j] = newIdList[j], newIdList[i] })
+	agent.data.agentId = agent.id
+	agent.data.shardParams(agent.id, agent.taskOps.NAgents)
+	// NOTE: The pre-population takes the other half of the streams
+	agent.data.setSeqStream(agent.id, 2*agent.taskOps.NAgents)
+
+	for _, phase := range agent.taskOps.Schedule {
+		data := newData(phase.dataOpts(agent.dataOpts), newIdList, agent.dataOpts.Seed+int64(agent.id))
+		data.agentId = agent.id
+		data.seq = agent.data.seq
+		agent.phaseData = append(agent.phaseData, data)
+	}
+
+	// NOTE: Not to share the source with the data, to generate the same SQL as the uniform arrival
+	if agent.taskOps.Arrival == ArrivalPoisson {
+		agent.arrivalRnd = rand.New(rand.NewSource(^(agent.dataOpts.Seed + int64(agent.id))))
+	}
+
+	agent.thinkRnd = rand.New(rand.NewSource(agent.dataOpts.Seed + int64(agent.id) + ThinkTimeSeedOffset))
+	agent.abandonRnd = rand.New(rand.NewSource(agent.dataOpts.Seed + int64(agent.id) + AbandonSeedOffset))
+
+	// NOTE: With '--pool-size', connections are taken from the pool for each query
+	if agent.pool != nil {
+		return nil
+	}
+
+	// NOTE: If no connection is available due to '--max-connections-total',
+	// connect when the agent starts running
+	if !agent.rsConfig.limiter.available() {
+		agent.deferred = true
+		return nil
+	}
+
+	// NOTE: Spread the connections over the ramp-up and the steps as well
+	if agent.taskOps.RampUp > 0 && agent.id > 0 || agent.taskOps.StepAgents > 0 && agent.id >= agent.taskOps.StepAgents {
+		return nil
+	}
+
+	return agent.connect(context.Background())
+}
+
+func (agent *Agent) connect(ctx context.Context) error {
+	conn, connectTime, err := agent.rsConfig.openAndPingTimed(ctx)
 
 	if err != nil {
 		dsn := agent.rsConfig.ConnString()
@@ -44,10 +139,13 @@ func (agent *Agent) prepare(idList []string) error {
 	}
 
 	agent.db = conn
-	newIdList := make([]string, len(idList))
-	copy(newIdList, idList)
-	rand.Shuffle(len(newIdList), func(i, j int) { newIdList[i], newIdList[j] = newIdList[j], newIdList[i] })
-	agent.data = newData(agent.dataOpts, newIdList)
+	agent.connectTime = connectTime
+
+	err = execPreQueries(conn, agent.data.PreQueries, agent.taskOps.ContinueOnPreQueryError, fmt.Sprintf("agent id=%d", agent.id))
+
+	if err != nil {
+		return err
+	}
 
 	inits := agent.data.initStmts()
 
@@ -62,39 +160,242 @@ func (agent *Agent) prepare(idList []string) error {
 	return nil
 }
 
-func (agent *Agent) run(ctx context.Context, recorder *Recorder) error {
+// Run pre-queries one by one in the given order.
+func execPreQueries(conn DB, preQueries []string, continueOnError bool, owner string) error {
+	for i, stmt := range preQueries {
+		_, err := conn.Exec(context.Background(), stmt)
+
+		if err != nil {
+			err = fmt.Errorf("failed to execute pre-query #%d of %d (%s, query=%s): %w", i+1, len(preQueries), owner, stmt, err)
+
+			if !continueOnError {
+				return err
+			}
+
+			fmt.Fprintf(os.Stderr, "[WARN] %s\n", err)
+		}
+	}
+
+	return nil
+}
+
+// NOTE: No new query is issued after stopCtx is done, and the running query is canceled when ctx is done
+func (agent *Agent) run(ctx context.Context, stopCtx context.Context, recorder *Recorder) error {
 	recordTick := time.NewTicker(RecordPeriod)
 	defer recordTick.Stop()
 	recDps := []recorderDataPoint{}
+	// for '--drain'
+	drainedCnt := 0
+	drainAbandonedCnt := 0
+
+	if agent.db == nil && agent.pool == nil {
+		err := agent.connect(ctx)
+
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+
+			return err
+		}
+	}
 
-	err := loopWithThrottle(agent.taskOps.Rate, agent.taskOps.Delay, agent.taskOps.Spread, func(i int) (bool, error) {
-		if agent.taskOps.NumberQueriesToExecute > 0 && i >= agent.taskOps.NumberQueriesToExecute {
+	// NOTE: The initial connection is made here or in prepare(), and the reconnections are not reported
+	if agent.pool == nil {
+		recorder.addConnectTime(agent.connectTime)
+	}
+
+	// NOTE: Done at the end of the current phase of '--schedule'
+	phaseCtx := stopCtx
+	proc := func(i int) (bool, error) {
+		// NOTE: The warm-up queries are executed in addition to the number of queries
+		if agent.taskOps.NumberQueriesToExecute > 0 && i >= agent.taskOps.NumberQueriesToExecute+agent.taskOps.WarmupQueries {
 			return false, nil
 		}
 
-		select {
-		case <-ctx.Done():
+		if agent.taskOps.WarmupQueries > 0 && i == agent.taskOps.WarmupQueries {
+			recorder.endWarmupQueries()
+		}
+
+		measured := i >= agent.taskOps.WarmupQueries
+
+		// NOTE: Check first, since the select picks one of the ready cases at random
+		if phaseCtx.Err() != nil {
 			return false, nil
+		}
+
+		select {
 		case <-recordTick.C:
 			recorder.add(recDps)
-			recDps = recDps[:0]
+			// NOTE: The recorder keeps reading the sent slice, so do not reuse it
+			recDps = make([]recorderDataPoint, 0, cap(recDps))
 		default:
 			// Nothing to do
 		}
 
+		if agent.globalLimiter != nil {
+			if err := agent.globalLimiter.wait(phaseCtx, agent.arrivalRnd); err != nil {
+				return false, nil
+			}
+		}
+
+		// NOTE: The first statement of the agent has no think-time
+		if agent.taskOps.StatementInterval > 0 && i > 0 {
+			select {
+			case <-time.After(drawThinkTime(agent.thinkRnd, agent.taskOps.DelayDistribution, agent.taskOps.StatementInterval, agent.taskOps.StatementIntervalSpread)):
+			case <-phaseCtx.Done():
+				return false, nil
+			}
+		}
+
+		prevBytesWritten := agent.data.bytesWritten
 		q, args := agent.data.next()
-		rt, err := agent.query(ctx, q, args...)
+
+		if err := agent.stageCopyData(ctx); err != nil {
+			return false, err
+		}
+		var rt time.Duration
+		var abandoned bool
+		var err error
+
+		if agent.pool != nil {
+			agent.db, agent.poolConn, err = agent.pool.acquire(phaseCtx)
+
+			if err != nil {
+				// NOTE: Canceled while waiting for a free connection
+				return false, nil
+			}
+		}
+
+		// NOTE: The connection is nil after a failed reconnection, e.g. of '--max-retries', so connect again
+		// or record the error of the connection as the error of the query
+		if agent.db == nil {
+			err = agent.connect(ctx)
+
+			if err != nil && ctx.Err() != nil {
+				return false, nil
+			}
+		}
+
+		switch {
+		case err != nil:
+			// NOTE: Failed to connect
+		case agent.taskOps.AbandonRate > 0 && agent.abandonRnd.Float64() < agent.taskOps.AbandonRate:
+			rt, abandoned, err = agent.queryAndAbandon(ctx, q, args...)
+		case agent.taskOps.MaxRetries > 0:
+			rt, err = agent.queryWithRetry(ctx, q, args...)
+		default:
+			rt, err = agent.query(ctx, q, args...)
+		}
+
+		// NOTE: pgconn closes the connection on a network error or a FATAL error, so reconnect for the next query
+		var reconnectErr error
+
+		if err != nil && ctx.Err() == nil {
+			reconnectErr = agent.reconnectIfClosed(ctx)
+		}
+
+		if agent.pool != nil {
+			agent.pool.release(agent.poolConn)
+			agent.db = nil
+			agent.poolConn = nil
+		}
+
+		if reconnectErr != nil {
+			return false, reconnectErr
+		}
+
+		// NOTE: The query was running when the test stopped. It is not recorded if it was canceled
+		if stopCtx.Err() != nil {
+			if ctx.Err() != nil {
+				drainAbandonedCnt++
+				return false, nil
+			}
+
+			drainedCnt++
+		}
 
 		if err != nil {
-			return false, fmt.Errorf("execute query error (query=%s, args=%v): %w", q, args, err)
+			// NOTE: With '--commit-rate', a failed query aborts the transaction, so roll it back and start a new one
+			rolledBack := false
+
+			if agent.data.CommitRate > 0 && !abandoned && agent.db != nil {
+				if _, rbErr := agent.db.Exec(ctx, "ROLLBACK"); rbErr != nil {
+					return false, fmt.Errorf("rollback error (agent id=%d, query=%s): %w", agent.id, q, rbErr)
+				}
+
+				agent.data.rollback()
+				rolledBack = true
+				fmt.Fprintf(os.Stderr, "\n[WARN] Rolled back the transaction (agent id=%d, query=%s): %s\n", agent.id, q, err)
+			}
+
+			// NOTE: The agent goes on after '--query-timeout', since the query was canceled by rsslap
+			if !agent.taskOps.ContinueOnError && !rolledBack && !errors.Is(err, errQueryTimeout) || abandoned {
+				return false, fmt.Errorf("execute query error (query=%s, args=%v): %w", q, args, err)
+			}
+
+			if !measured {
+				recorder.addUnmeasured(1)
+				return true, nil
+			}
+
+			code := errorType(err)
+			recDps = append(recDps, recorderDataPoint{
+				timestamp: time.Now(),
+				resTime:   rt,
+				agentId:   int32(agent.id),
+				queryIdx:  int32(i),
+				query:     recorder.strs.index(q),
+				queryType: recorder.strs.index(agent.data.queryType),
+				queryTag:  recorder.strs.index(agent.data.queryTag),
+				errKind:   recorder.errorKindIndex(code, errorMessage(err)),
+				failed:    true,
+				timedOut:  code == ErrorTypeTimeout,
+			})
+
+			return true, nil
+		}
+
+		if abandoned {
+			agent.abandonCnt++
+			return true, nil
+		}
+
+		agent.lastResTime = rt
+		now := time.Now()
+
+		if measured {
+			recDps = append(recDps, recorderDataPoint{
+				timestamp: now,
+				resTime:   rt,
+				agentId:   int32(agent.id),
+				queryIdx:  int32(i),
+				query:     recorder.strs.index(q),
+				queryType: recorder.strs.index(agent.data.queryType),
+				queryTag:  recorder.strs.index(agent.data.queryTag),
+			})
+		} else {
+			recorder.addUnmeasured(1)
+		}
+
+		if bytesWritten := agent.data.bytesWritten - prevBytesWritten; bytesWritten > 0 {
+			total := recorder.addBytesWritten(bytesWritten)
+
+			if agent.taskOps.MaxBytesWritten > 0 && uint64(total) >= agent.taskOps.MaxBytesWritten {
+				return false, nil
+			}
 		}
 
-		recDps = append(recDps, recorderDataPoint{
-			timestamp: time.Now(),
-			resTime:   rt,
-		})
+		if agent.taskOps.Probe {
+			fmt.Fprintf(os.Stderr, "[PROBE] %s %s\n", now.Format(time.RFC3339Nano), rt)
+		}
 
 		return true, nil
+	}
+
+	err := agent.runPhases(stopCtx, recorder, func(ctx context.Context, rate float64) error {
+		phaseCtx = ctx
+		return loopWithThrottle(rate, agent.taskOps.Delay, agent.taskOps.Spread, agent.taskOps.DelayDistribution, agent.arrivalRnd, agent.thinkRnd, proc)
 	})
 
 	if err != nil {
@@ -103,13 +404,31 @@ func (agent *Agent) run(ctx context.Context, recorder *Recorder) error {
 
 	// at least record what we have at the end of the loop
 	recorder.add(recDps)
-	recDps = recDps[:0]
+
+	if agent.taskOps.ChecksumResults {
+		recorder.addChecksum(agent.checksum, agent.rowCnt)
+	}
+
+	recorder.addAbandoned(agent.abandonCnt)
+	recorder.addDrained(drainedCnt, drainAbandonedCnt)
+	recorder.addRetries(agent.retryCnt)
+	recorder.addResultMemLimitExceeded(agent.resultMemLimitCnt)
+
+	// Hand over the connection to the waiting agents
+	if agent.rsConfig.limiter != nil {
+		return agent.close()
+	}
 
 	return nil
 }
 
 func (agent *Agent) close() error {
-	err := agent.db.Close(context.Background())
+	if agent.db == nil {
+		return nil
+	}
+
+	err := agent.rsConfig.closeConn(agent.db)
+	agent.db = nil
 
 	if err != nil {
 		return fmt.Errorf("failed to close DB (agent id=%d): %w", agent.id, err)
@@ -119,19 +438,187 @@ func (agent *Agent) close() error {
 }
 
 func (agent *Agent) query(ctx context.Context, q string, args ...interface{}) (time.Duration, error) {
+	queryCtx := ctx
+
+	if agent.taskOps.QueryTimeout > 0 {
+		var cancel context.CancelFunc
+		queryCtx, cancel = context.WithTimeout(ctx, agent.taskOps.QueryTimeout)
+		defer cancel()
+	}
+
 	start := time.Now()
-	_, err := agent.db.Exec(ctx, q, args...)
+	err := agent.execute(queryCtx, q, args...)
 	end := time.Now()
 
-	if err != nil && !errors.Is(err, context.Canceled) && !pgconn.Timeout(err) {
-		// NOTE: Connection may close due to timeout..
-		// cf.
-		// * https://github.com/jackc/pgconn/blob/a50d96d4915cae7d1a28601ce9e7a57b0ea5ae41/errors.go#L20-L21
-		// * https://github.com/jackc/pgconn/issues/81
-		if pgxConn, ok := agent.db.(*pgx.Conn); !ok || !pgxConn.IsClosed() {
-			return 0, err
+	if err == nil {
+		return end.Sub(start), nil
+	}
+
+	// NOTE: The running query was canceled at the end of the test, which may also close the connection
+	// cf.
+	// * https://github.com/jackc/pgconn/blob/a50d96d4915cae7d1a28601ce9e7a57b0ea5ae41/errors.go#L20-L21
+	// * https://github.com/jackc/pgconn/issues/81
+	if ctx.Err() != nil {
+		return end.Sub(start), nil
+	}
+
+	// NOTE: The deadline of '--query-timeout' expired, not the test
+	if errors.Is(queryCtx.Err(), context.DeadlineExceeded) {
+		return end.Sub(start), fmt.Errorf("%w (timeout=%s): %s", errQueryTimeout, agent.taskOps.QueryTimeout, err)
+	}
+
+	return end.Sub(start), err
+}
+
+// Replace the connection closed by pgx, e.g. by a canceled query or a network error.
+func (agent *Agent) reconnectIfClosed(ctx context.Context) error {
+	if pgxConn, ok := agent.db.(*pgx.Conn); !ok || !pgxConn.IsClosed() {
+		return nil
+	}
+
+	// NOTE: pgxpool replaces the closed connection of '--pool-size' when it is released
+	if agent.pool != nil {
+		return nil
+	}
+
+	_ = agent.rsConfig.closeConn(agent.db)
+	agent.db = nil
+	err := agent.connect(ctx)
+
+	if err != nil {
+		return fmt.Errorf("failed to reconnect after the connection was closed (agent id=%d): %w", agent.id, err)
+	}
+
+	return nil
+}
+
+// Forcibly close the connection while the query is running, and reconnect.
+func (agent *Agent) queryAndAbandon(ctx context.Context, q string, args ...interface{}) (time.Duration, bool, error) {
+	pgxConn, ok := agent.db.(*pgx.Conn)
+
+	if !ok {
+		rt, err := agent.query(ctx, q, args...)
+		return rt, false, err
+	}
+
+	// NOTE: Abandon the query about halfway through, based on the last response time
+	delay := agent.lastResTime / 2
+
+	if delay < AbandonMinDelay {
+		delay = AbandonMinDelay
+	}
+
+	var fired int32
+	timer := time.AfterFunc(delay, func() {
+		atomic.StoreInt32(&fired, 1)
+		pgxConn.PgConn().Conn().Close()
+	})
+
+	rt, err := agent.query(ctx, q, args...)
+
+	if timer.Stop() || atomic.LoadInt32(&fired) == 0 {
+		return rt, false, err
+	}
+
+	_ = agent.rsConfig.closeConn(agent.db)
+	agent.db = nil
+	err = agent.connect(ctx)
+
+	if err != nil {
+		return 0, true, fmt.Errorf("failed to reconnect after abandoning query (agent id=%d): %w", agent.id, err)
+	}
+
+	return 0, true, nil
+}
+
+func (agent *Agent) execute(ctx context.Context, q string, args ...interface{}) error {
+	if agent.taskOps.ChecksumResults || agent.taskOps.AgentResultMemLimit > 0 {
+		return agent.queryRows(ctx, q, args...)
+	}
+
+	if agent.dataOpts.LoadType == LoadTypeCopy && agent.dataOpts.CopyS3Prefix == "" && strings.HasPrefix(q, "COPY ") {
+		return agent.copyFrom(ctx, q)
+	}
+
+	if agent.taskOps.DiscardResults {
+		return agent.discardRows(ctx, q, args...)
+	}
+
+	_, err := agent.db.Exec(ctx, q, args...)
+	return err
+}
+
+// Skip the returned rows without buffering them.
+// NOTE: Exec reads the whole result into memory, while closing the rows discards each row as it arrives.
+func (agent *Agent) discardRows(ctx context.Context, q string, args ...interface{}) error {
+	rows, err := agent.db.Query(ctx, q, args...)
+
+	if err != nil || rows == nil {
+		return err
+	}
+
+	rows.Close()
+	return rows.Err()
+}
+
+// Read all returned rows, buffering them up to '--agent-result-mem-limit' and adding their hashes to the checksum.
+// NOTE: The sum of the row hashes does not depend on the order in which the rows are returned.
+func (agent *Agent) queryRows(ctx context.Context, q string, args ...interface{}) error {
+	rows, err := agent.db.Query(ctx, q, args...)
+
+	if err != nil || rows == nil {
+		return err
+	}
+
+	defer rows.Close()
+	var lenBuf [4]byte
+	agent.resultBuf = agent.resultBuf[:0]
+	buffering := agent.taskOps.AgentResultMemLimit > 0
+	bufSize := uint64(0)
+
+	for rows.Next() {
+		raw := rows.RawValues()
+
+		if buffering {
+			for _, v := range raw {
+				bufSize += uint64(len(v))
+			}
+
+			if bufSize > agent.taskOps.AgentResultMemLimit {
+				agent.resultMemLimitCnt++
+
+				if agent.taskOps.AgentResultMemAction == ResultMemActionError {
+					return fmt.Errorf("result exceeded the agent result memory limit (%d bytes)", agent.taskOps.AgentResultMemLimit)
+				}
+
+				// Switch to streaming for the rest of the result
+				buffering = false
+				agent.resultBuf = agent.resultBuf[:0]
+			} else {
+				for _, v := range raw {
+					agent.resultBuf = append(agent.resultBuf, append([]byte(nil), v...))
+				}
+			}
+		}
+
+		if agent.taskOps.ChecksumResults {
+			agent.hasher.Reset()
+
+			for _, v := range raw {
+				if v == nil {
+					binary.BigEndian.PutUint32(lenBuf[:], 0xffffffff)
+				} else {
+					binary.BigEndian.PutUint32(lenBuf[:], uint32(len(v)))
+				}
+
+				agent.hasher.Write(lenBuf[:])
+				agent.hasher.Write(v)
+			}
+
+			agent.checksum += agent.hasher.Sum64()
+			agent.rowCnt++
 		}
 	}
 
-	return end.Sub(start), nil
+	return rows.Err()
 }
@@ -0,0 +1,136 @@
+package rsslap
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// Null DB which records the executed statements.
+type recordingDB struct {
+	NullDB
+	stmts []string
+}
+
+func (db *recordingDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	db.stmts = append(db.stmts, sql)
+	return nil, nil
+}
+
+// DB whose connection is lost at the first query.
+type brokenDB struct {
+	NullDB
+}
+
+func (db *brokenDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return nil, io.ErrUnexpectedEOF
+}
+
+// Each agent executes '--number-queries' queries in the order of '--query-distribution'.
+func TestAgentRunNumberQueries(t *testing.T) {
+	queries := []string{"select 1", "select 2", "select 3"}
+
+	tests := []struct {
+		name          string
+		distribution  QueryDistribution
+		numberQueries int
+		want          []string
+	}{
+		{"sequential", QueryDistributionSequential, 5, []string{"select 1", "select 2", "select 3", "select 1", "select 2"}},
+		{"sequential of one pass", QueryDistributionSequential, 3, []string{"select 1", "select 2", "select 3"}},
+		{"round-robin", QueryDistributionRoundRobin, 4, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const nAgents = 3
+			taskOpts := &TaskOpts{RsConfig: &RsConfig{OnlyPrint: true}, NAgents: nAgents, NumberQueriesToExecute: tt.numberQueries}
+			dataOpts := &DataOpts{Queries: queries, QueryDistribution: tt.distribution}
+			rec := newRecorder(&RecorderOpts{}, taskOpts, dataOpts)
+
+			if err := rec.start(nAgents * tt.numberQueries); err != nil {
+				t.Fatal(err)
+			}
+
+			dbs := make([]*recordingDB, nAgents)
+
+			for i := range dbs {
+				agent := newAgent(i, taskOpts.RsConfig, taskOpts, dataOpts)
+
+				if err := agent.prepare(nil); err != nil {
+					t.Fatal(err)
+				}
+
+				dbs[i] = &recordingDB{}
+				agent.db = dbs[i]
+
+				if err := agent.run(context.Background(), context.Background(), rec); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			rec.close()
+
+			for i, db := range dbs {
+				want := tt.want
+
+				// NOTE: Each agent repeats its own query of round-robin
+				if want == nil {
+					for n := 0; n < tt.numberQueries; n++ {
+						want = append(want, queries[i%len(queries)])
+					}
+				}
+
+				if !reflect.DeepEqual(db.stmts, want) {
+					t.Errorf("agent %d executed %v, want %v", i, db.stmts, want)
+				}
+			}
+
+			if got := rec.Count(); got != nAgents*tt.numberQueries {
+				t.Errorf("Count() = %d, want %d", got, nAgents*tt.numberQueries)
+			}
+		})
+	}
+}
+
+// With '--continue-on-error', the failed reconnection of '--max-retries' is recorded as the error of the next
+// queries instead of executing them on the nil connection, also of '--abandon-rate'.
+func TestAgentRunReconnectError(t *testing.T) {
+	connConfig, err := pgx.ParseConfig("postgres://scott@127.0.0.1:1/db?connect_timeout=1")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	taskOpts := &TaskOpts{RsConfig: &RsConfig{OnlyPrint: true}, NAgents: 1, NumberQueriesToExecute: 20, ContinueOnError: true, MaxRetries: 1, AbandonRate: 0.5}
+	dataOpts := &DataOpts{Queries: []string{"select 1"}, QueryDistribution: QueryDistributionSequential}
+	rec := newRecorder(&RecorderOpts{}, taskOpts, dataOpts)
+
+	if err := rec.start(20); err != nil {
+		t.Fatal(err)
+	}
+
+	agent := newAgent(0, taskOpts.RsConfig, taskOpts, dataOpts)
+
+	if err := agent.prepare(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// NOTE: The DB of the reconnection is not reachable
+	agent.db = &brokenDB{}
+	agent.rsConfig = &RsConfig{ConnConfig: connConfig}
+
+	if err := agent.run(context.Background(), context.Background(), rec); err != nil {
+		t.Fatal(err)
+	}
+
+	rec.close()
+
+	if got := len(rec.errorDataPoints); got != 20 {
+		t.Errorf("errors = %d, want 20", got)
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/integrii/flaggy"
+)
+
+const (
+	ConfigQueriesKey = "queries"
+)
+
+// Settings loaded from '--config'.
+type config struct {
+	values  []configValue
+	queries []string
+}
+
+// Arguments of a key of the config file, e.g. ["--nagents", "10"].
+type configValue struct {
+	key  string
+	args []string
+}
+
+// Find the '--config' file in the command line arguments and load it.
+func loadConfigFromArgs(args []string) (*config, error) {
+	path := ""
+
+	for i, arg := range args {
+		if arg == "--config" && i+1 < len(args) {
+			path = args[i+1]
+		} else if strings.HasPrefix(arg, "--config=") {
+			path = strings.TrimPrefix(arg, "--config=")
+		}
+	}
+
+	if path == "" {
+		return &config{}, nil
+	}
+
+	raw, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read the config file: %w", err)
+	}
+
+	cfg, err := parseConfig(string(raw))
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file (path=%s): %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// Parse a YAML mapping of the long flag names, e.g. "nagents: 10".
+// NOTE: Only scalars and the 'queries' list of strings are supported
+func parseConfig(src string) (*config, error) {
+	flagByName := map[string]*flaggy.Flag{}
+
+	for _, f := range flaggy.DefaultParser.Flags {
+		flagByName[f.LongName] = f
+	}
+
+	cfg := &config{}
+	listKey := ""
+	seen := map[string]bool{}
+
+	for i, line := range strings.Split(src, "\n") {
+		lineNum := i + 1
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			if listKey == "" {
+				return nil, fmt.Errorf("list item without a key (line %d)", lineNum)
+			}
+
+			value, err := parseConfigScalar(strings.TrimPrefix(trimmed, "-"))
+
+			if err != nil {
+				return nil, fmt.Errorf("%w (line %d)", err, lineNum)
+			}
+
+			cfg.queries = append(cfg.queries, value)
+			continue
+		}
+
+		if line != strings.TrimLeft(line, " \t") {
+			return nil, fmt.Errorf("nested values are not supported (line %d)", lineNum)
+		}
+
+		idx := strings.Index(trimmed, ":")
+
+		if idx < 0 {
+			return nil, fmt.Errorf("missing ':' (line %d)", lineNum)
+		}
+
+		key := strings.TrimSpace(trimmed[:idx])
+		value, err := parseConfigScalar(trimmed[idx+1:])
+
+		if err != nil {
+			return nil, fmt.Errorf("%w (line %d)", err, lineNum)
+		}
+
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate key '%s' (line %d)", key, lineNum)
+		}
+
+		seen[key] = true
+		listKey = ""
+
+		if key == ConfigQueriesKey {
+			if value != "" {
+				return nil, fmt.Errorf("'%s' must be a list (line %d)", ConfigQueriesKey, lineNum)
+			}
+
+			listKey = key
+			continue
+		}
+
+		f, ok := flagByName[key]
+
+		if !ok || key == "config" {
+			return nil, fmt.Errorf("unknown key '%s' (line %d)", key, lineNum)
+		}
+
+		if _, isBool := f.AssignmentVar.(*bool); isBool {
+			b, err := strconv.ParseBool(value)
+
+			if err != nil {
+				return nil, fmt.Errorf("'%s' must be true or false (line %d)", key, lineNum)
+			}
+
+			if b {
+				cfg.values = append(cfg.values, configValue{key: key, args: []string{"--" + key}})
+			}
+
+			continue
+		}
+
+		cfg.values = append(cfg.values, configValue{key: key, args: []string{"--" + key, value}})
+	}
+
+	return cfg, nil
+}
+
+// Return the arguments of the keys of the config file whose flags are not set in the command line arguments.
+// NOTE: Not to add the values of the config file to a slice flag of the command line, e.g. '--query',
+// and not to set a bool flag that the command line sets to false, e.g. '--drain=false'
+func (cfg *config) argsUnsetIn(cliArgs []string) []string {
+	set := flagNamesOf(cliArgs)
+	args := []string{}
+
+	for _, v := range cfg.values {
+		if !set[v.key] {
+			args = append(args, v.args...)
+		}
+	}
+
+	return args
+}
+
+// Return the long names of the flags in the command line arguments.
+func flagNamesOf(args []string) map[string]bool {
+	longNames := map[string]string{}
+
+	for _, f := range flaggy.DefaultParser.Flags {
+		if f.ShortName != "" {
+			longNames[f.ShortName] = f.LongName
+		}
+	}
+
+	names := map[string]bool{}
+
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+
+		if strings.HasPrefix(arg, "--") {
+			names[strings.SplitN(arg[2:], "=", 2)[0]] = true
+		} else if strings.HasPrefix(arg, "-") {
+			if long, ok := longNames[strings.SplitN(arg[1:], "=", 2)[0]]; ok {
+				names[long] = true
+			}
+		}
+	}
+
+	return names
+}
+
+func parseConfigScalar(s string) (string, error) {
+	s = strings.TrimSpace(s)
+
+	// NOTE: Flow collections, block scalars, anchors, aliases, tags and the reserved indicators
+	if s != "" && strings.ContainsAny(s[:1], "[]{}|>&*!%@`") {
+		return "", fmt.Errorf("unsupported YAML syntax: %s", s)
+	}
+
+	if strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "'") {
+		quote := s[:1]
+		end := strings.LastIndex(s, quote)
+		rest := strings.TrimSpace(s[end+1:])
+
+		// NOTE: Only a comment may follow the closing quote
+		if end == 0 || rest != "" && !strings.HasPrefix(rest, "#") {
+			return "", fmt.Errorf("invalid quoted string: %s", s)
+		}
+
+		if quote == `"` {
+			return strconv.Unquote(s[:end+1])
+		}
+
+		return strings.ReplaceAll(s[1:end], "''", "'"), nil
+	}
+
+	// Strip the comment
+	if idx := strings.Index(s, " #"); idx >= 0 {
+		s = strings.TrimSpace(s[:idx])
+	}
+
+	return s, nil
+}
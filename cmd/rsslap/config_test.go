@@ -0,0 +1,97 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/integrii/flaggy"
+)
+
+// The command line takes precedence over the config file, also for the slice and the bool flags.
+func TestConfigPrecedence(t *testing.T) {
+	src := strings.Join([]string{
+		"query:  select 1",
+		"pre-query: set a to 1",
+		"drain: true",
+		"nagents: 10",
+	}, "\n")
+
+	tests := []struct {
+		name     string
+		cli      []string
+		queries  []string
+		preQuery []string
+		drain    bool
+		nAgents  int
+	}{
+		{"config only", []string{}, []string{"select 1"}, []string{"set a to 1"}, true, 10},
+		{"slice flag of the command line", []string{"-q", "select 2", "--query", "select 3"}, []string{"select 2", "select 3"}, []string{"set a to 1"}, true, 10},
+		{"bool flag set to false", []string{"--drain=false", "--nagents=2"}, []string{"select 1"}, []string{"set a to 1"}, false, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flaggy.ResetParser()
+			var queries, preQuery []string
+			var drain bool
+			var nAgents int
+			flaggy.StringSlice(&queries, "q", "query", "")
+			flaggy.StringSlice(&preQuery, "", "pre-query", "")
+			flaggy.Bool(&drain, "", "drain", "")
+			flaggy.Int(&nAgents, "n", "nagents", "")
+			cfg, err := parseConfig(src)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			flaggy.ParseArgs(append(cfg.argsUnsetIn(tt.cli), tt.cli...))
+
+			if !reflect.DeepEqual(queries, tt.queries) {
+				t.Errorf("query = %v, want %v", queries, tt.queries)
+			}
+
+			if !reflect.DeepEqual(preQuery, tt.preQuery) {
+				t.Errorf("pre-query = %v, want %v", preQuery, tt.preQuery)
+			}
+
+			if drain != tt.drain {
+				t.Errorf("drain = %v, want %v", drain, tt.drain)
+			}
+
+			if nAgents != tt.nAgents {
+				t.Errorf("nagents = %d, want %d", nAgents, tt.nAgents)
+			}
+		})
+	}
+}
+
+func TestParseConfigUnsupportedSyntax(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"nagents: 1\nquery: [select 1, select 2]", "(line 2)"},
+		{"query: |\n  select 1", "(line 1)"},
+		{"nagents: &n 1", "(line 1)"},
+		{"nagents: 1\n\nnagents: 2", "duplicate key 'nagents' (line 3)"},
+		{"nagents: 1\n  nested: 2", "nested values are not supported (line 2)"},
+	}
+
+	for _, tt := range tests {
+		flaggy.ResetParser()
+		var nAgents int
+		var queries []string
+		var drain bool
+		flaggy.Int(&nAgents, "n", "nagents", "")
+		flaggy.StringSlice(&queries, "q", "query", "")
+		flaggy.Bool(&drain, "", "drain", "")
+
+		_, err := parseConfig(tt.src)
+
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseConfig(%q) error = %v, want %s", tt.src, err, tt.want)
+		}
+	}
+}
@@ -1,39 +1,153 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"os"
 	"rsslap"
+	"sync"
 )
 
 func main() {
 	flags := parseFlags()
-	_ = flags
-	task := rsslap.NewTask(&flags.TaskOpts, &flags.DataOpts, &flags.RecorderOpts)
+
+	if flags.DryRunRows > 0 {
+		err := rsslap.PrintSampleRows(os.Stdout, &flags.DataOpts, flags.DryRunRows)
+
+		if err != nil {
+			log.Fatalf("Failed to print sample rows: %s", err)
+		}
+
+		return
+	}
+
+	if flags.RsConfig2 != nil {
+		compare(flags)
+		return
+	}
+
+	rec := run(&flags.TaskOpts, &flags.DataOpts, &flags.RecorderOpts)
+
+	if !flags.OnlyPrint {
+		report := rec.Report()
+		err := writeReport(flags, func(w io.Writer) error {
+			return report.Write(w, flags.OutputFormat.Formatter())
+		})
+
+		if err != nil {
+			log.Fatalf("Failed to write report: %s", err)
+		}
+
+		if report.AbortReason != "" || !slaPassed(report) {
+			os.Exit(1)
+		}
+	}
+}
+
+func run(taskOpts *rsslap.TaskOpts, dataOpts *rsslap.DataOpts, recOpts *rsslap.RecorderOpts) *rsslap.Recorder {
+	task := rsslap.NewTask(taskOpts, dataOpts, recOpts)
 
 	err := task.Prepare()
 
 	if err != nil {
-		log.Fatalf("Failed to prepare Task: %s", err)
+		log.Fatalf("Failed to prepare Task (url=%s): %s", recOpts.URL, err)
 	}
 
 	rec, err := task.Run()
-	_ = rec
 
 	if err != nil {
-		log.Fatalf("Failed to run Task: %s", err)
+		log.Fatalf("Failed to run Task (url=%s): %s", recOpts.URL, err)
 	}
 
 	err = task.Close()
 
 	if err != nil {
-		log.Fatalf("Failed to close Task: %s", err)
+		log.Fatalf("Failed to close Task (url=%s): %s", recOpts.URL, err)
 	}
 
-	if !flags.OnlyPrint {
+	return rec
+}
+
+// Run the same workload against '--url' and '--url2' at the same time, and report them side by side.
+func compare(flags *Flags) {
+	taskOpts2 := flags.TaskOpts
+	taskOpts2.RsConfig = flags.RsConfig2
+	dataOpts2 := flags.DataOpts
+
+	// NOTE: The progress and the profiles are only for the first URL
+	taskOpts2.NoProgress = true
+	taskOpts2.Dashboard = false
+	taskOpts2.LiveHistogram = false
+	taskOpts2.CPUProfile = ""
+	taskOpts2.MemProfile = ""
+
+	// NOTE: The live outputs are only for the first URL
+	recOpts2 := flags.RecorderOpts
+	recOpts2.URL = flags.URL2
+	recOpts2.SamplesSocket = ""
+	recOpts2.LatencyCSV = ""
+	recOpts2.CSVOutput = ""
+	recOpts2.IntervalReport = 0
+	recOpts2.IntervalReportFile = ""
+	recOpts2.PrometheusAddr = ""
+	recOpts2.StatsdAddr = ""
+
+	recs := make([]*rsslap.Recorder, 2)
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		recs[0] = run(&flags.TaskOpts, &flags.DataOpts, &flags.RecorderOpts)
+	}()
+
+	go func() {
+		defer wg.Done()
+		recs[1] = run(&taskOpts2, &dataOpts2, &recOpts2)
+	}()
+
+	wg.Wait()
+
+	if flags.OnlyPrint {
+		return
+	}
+
+	cr := &rsslap.ComparisonReport{}
+	failed := false
+
+	for _, rec := range recs {
 		report := rec.Report()
-		rawJson, _ := json.MarshalIndent(report, "", "  ")
-		fmt.Println(string(rawJson))
+		cr.Reports = append(cr.Reports, report)
+		failed = failed || report.AbortReason != ""
+	}
+
+	err := writeReport(flags, func(w io.Writer) error {
+		return cr.Write(w, flags.OutputFormat)
+	})
+
+	if err != nil {
+		log.Fatalf("Failed to write report: %s", err)
 	}
+
+	for _, report := range cr.Reports {
+		failed = !slaPassed(report) || failed
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// Print the tripped thresholds of '--sla-p99' and '--sla-error-rate' for the CI log.
+func slaPassed(report *rsslap.RecorderReport) bool {
+	if report.SLA == nil || report.SLA.Passed() {
+		return true
+	}
+
+	for _, v := range report.SLA.Violations {
+		fmt.Fprintf(os.Stderr, "[ERROR] SLA violated (url=%s): %s\n", report.URL, v)
+	}
+
+	return false
 }
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Write the report to stdout, or to the '--output' file.
+func writeReport(flags *Flags, write func(w io.Writer) error) error {
+	if flags.Output == "" {
+		return write(os.Stdout)
+	}
+
+	mode := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+
+	if flags.OutputAppend {
+		mode = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	f, err := os.OpenFile(flags.Output, mode, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	// NOTE: Separate the runs accumulated in the same file
+	if flags.OutputAppend {
+		header := "=== rsslap run at " + time.Now().Format(time.RFC3339)
+
+		if flags.OutputLabel != "" {
+			header += " (" + flags.OutputLabel + ")"
+		}
+
+		if _, err := fmt.Fprintln(f, header+" ==="); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"rsslap"
+	"strings"
+	"time"
+)
+
+// Phase in the '--schedule' file, e.g.
+// {"name": "heavy", "duration": "10m", "nagents": 16, "load_type": "mixed", "mixed_sel_ins_ratio": "3:1"}
+type schedulePhaseJSON struct {
+	Name             string  `json:"name"`
+	Duration         string  `json:"duration"`
+	NAgents          int     `json:"nagents"`
+	Rate             float64 `json:"rate"`
+	LoadType         string  `json:"load_type"`
+	Query            string  `json:"query"`
+	MixedSelInsRatio string  `json:"mixed_sel_ins_ratio"`
+}
+
+// Load the JSON list of the phases of '--schedule'.
+func loadSchedule(path string, delimiter string) ([]rsslap.SchedulePhase, error) {
+	raw, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read the schedule file: %w", err)
+	}
+
+	var phasesJSON []schedulePhaseJSON
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&phasesJSON); err != nil {
+		return nil, fmt.Errorf("invalid schedule file (path=%s): %w", path, err)
+	}
+
+	if len(phasesJSON) == 0 {
+		return nil, fmt.Errorf("no phases in the schedule file (path=%s)", path)
+	}
+
+	phases := make([]rsslap.SchedulePhase, len(phasesJSON))
+
+	for i, pj := range phasesJSON {
+		phase := &phases[i]
+		phase.Name = pj.Name
+
+		if phase.Name == "" {
+			phase.Name = fmt.Sprintf("phase%d", i+1)
+		}
+
+		phase.Duration, err = time.ParseDuration(pj.Duration)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse 'duration' of %s: %w", phase.Name, err)
+		} else if phase.Duration <= 0 {
+			return nil, fmt.Errorf("'duration' of %s must be > 0", phase.Name)
+		}
+
+		if pj.NAgents < 1 {
+			return nil, fmt.Errorf("'nagents' of %s must be >= 1", phase.Name)
+		}
+
+		phase.NAgents = pj.NAgents
+
+		if pj.Rate < 0 {
+			return nil, fmt.Errorf("'rate' of %s must be >= 0", phase.Name)
+		}
+
+		phase.Rate = pj.Rate
+
+		if pj.LoadType != "" && pj.Query != "" {
+			return nil, fmt.Errorf("cannot set both 'load_type' and 'query' of %s", phase.Name)
+		}
+
+		if pj.LoadType != "" {
+			phase.LoadType = rsslap.AutoGenerateSqlLoadType(pj.LoadType)
+
+			if !isValidLoadType(phase.LoadType) {
+				return nil, fmt.Errorf("invalid 'load_type' of %s: %s", phase.Name, pj.LoadType)
+			}
+		}
+
+		if pj.Query != "" {
+			phase.Queries = filterEmptyQuery(strings.Split(pj.Query, delimiter))
+		}
+
+		if pj.MixedSelInsRatio != "" {
+			phase.MixedSelRatio, phase.MixedInsRatio, err = parseMixedSelInsRatio(pj.MixedSelInsRatio)
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid 'mixed_sel_ins_ratio' of %s: %w", phase.Name, err)
+			}
+		}
+	}
+
+	return phases, nil
+}
@@ -0,0 +1,95 @@
+package rsslap
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+type ColdWarmReport struct {
+	Query     string
+	ColdTime  time.Duration
+	WarmCount int
+	WarmAvg   time.Duration
+	WarmP50   time.Duration
+	WarmP99   time.Duration
+}
+
+// Report the first (cold) execution of each distinct query separately from its subsequent (warm) executions.
+func (rec *Recorder) coldWarm() []ColdWarmReport {
+	type queryTimes struct {
+		coldStart time.Time
+		coldTime  time.Duration
+		warmTimes []time.Duration
+	}
+
+	byQuery := map[int32]*queryTimes{}
+
+	for _, v := range rec.dataPoints {
+		start := v.timestamp.Add(-v.resTime)
+		qt, ok := byQuery[v.query]
+
+		if !ok {
+			byQuery[v.query] = &queryTimes{coldStart: start, coldTime: v.resTime}
+			continue
+		}
+
+		if start.Before(qt.coldStart) {
+			qt.warmTimes = append(qt.warmTimes, qt.coldTime)
+			qt.coldStart = start
+			qt.coldTime = v.resTime
+		} else {
+			qt.warmTimes = append(qt.warmTimes, v.resTime)
+		}
+	}
+
+	reports := make([]ColdWarmReport, 0, len(byQuery))
+	coldStarts := make(map[string]time.Time, len(byQuery))
+
+	for q, qt := range byQuery {
+		cw := ColdWarmReport{
+			Query:     rec.strs.get(q),
+			ColdTime:  qt.coldTime,
+			WarmCount: len(qt.warmTimes),
+		}
+
+		if len(qt.warmTimes) > 0 {
+			sort.Slice(qt.warmTimes, func(i, j int) bool { return qt.warmTimes[i] < qt.warmTimes[j] })
+			var total time.Duration
+
+			for _, t := range qt.warmTimes {
+				total += t
+			}
+
+			cw.WarmAvg = total / time.Duration(len(qt.warmTimes))
+			cw.WarmP50 = percentile(qt.warmTimes, 50)
+			cw.WarmP99 = percentile(qt.warmTimes, 99)
+		}
+
+		reports = append(reports, cw)
+		coldStarts[cw.Query] = qt.coldStart
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		return coldStarts[reports[i].Query].Before(coldStarts[reports[j].Query])
+	})
+
+	return reports
+}
+
+// Nearest-rank percentile of sorted response times.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+
+	if rank < 1 {
+		rank = 1
+	} else if rank > len(sorted) {
+		rank = len(sorted)
+	}
+
+	return sorted[rank-1]
+}
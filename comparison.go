@@ -0,0 +1,121 @@
+package rsslap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Reports of the same workload run against several URLs at the same time.
+type ComparisonReport struct {
+	Reports []*RecorderReport
+}
+
+// Write the reports as a JSON array, side by side in the text format, or one after another in Markdown.
+func (cr *ComparisonReport) Write(w io.Writer, format OutputFormat) error {
+	if format == OutputFormatText {
+		return cr.writeText(w)
+	} else if format == OutputFormatMarkdown {
+		for i, rr := range cr.Reports {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+
+			if err := rr.Write(w, &MarkdownFormatter{}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	rawJson, err := json.MarshalIndent(cr.Reports, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w, string(rawJson))
+	return err
+}
+
+func (cr *ComparisonReport) writeText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	row := func(name string, value func(rr *RecorderReport) string) {
+		cols := []string{name}
+
+		for _, rr := range cr.Reports {
+			cols = append(cols, value(rr))
+		}
+
+		fmt.Fprintln(tw, strings.Join(cols, "\t"))
+	}
+
+	row("URL", func(rr *RecorderReport) string { return rr.URL })
+	row("Elapsed time", func(rr *RecorderReport) string { return fmt.Sprintf("%ds", rr.ElapsedTime) })
+	row("Queries", func(rr *RecorderReport) string { return strconv.Itoa(rr.QueryCount) })
+	row("Errors", func(rr *RecorderReport) string { return strconv.Itoa(rr.ErrorCount) })
+
+	if len(cr.Reports) > 0 && cr.Reports[0].QueryTimeout > 0 {
+		row("Timeouts", func(rr *RecorderReport) string { return strconv.Itoa(rr.TimeoutCount) })
+	}
+
+	row("QPS (avg)", func(rr *RecorderReport) string { return fmt.Sprintf("%.1f", rr.AvgQPS) })
+	row("QPS (median)", func(rr *RecorderReport) string { return fmt.Sprintf("%.1f", rr.MedianQPS) })
+
+	response := func(value func(m *ResponseMetrics) time.Duration) func(rr *RecorderReport) string {
+		return func(rr *RecorderReport) string {
+			if rr.Response == nil {
+				return "-"
+			}
+
+			return value(rr.Response).String()
+		}
+	}
+
+	row("Response avg", response(func(m *ResponseMetrics) time.Duration { return m.Avg }))
+	row("Response min", response(func(m *ResponseMetrics) time.Duration { return m.Min }))
+
+	// NOTE: All reports have the same percentiles
+	if len(cr.Reports) > 0 && cr.Reports[0].Response != nil {
+		for i, p := range cr.Reports[0].Response.Percentiles {
+			idx := i
+			row("Response p"+strconv.FormatFloat(p.Percentile, 'f', -1, 64), response(func(m *ResponseMetrics) time.Duration {
+				if idx >= len(m.Percentiles) {
+					return 0
+				}
+
+				return m.Percentiles[idx].Value
+			}))
+		}
+	}
+
+	row("Response max", response(func(m *ResponseMetrics) time.Duration { return m.Max }))
+	row("Connect time (avg)", func(rr *RecorderReport) string {
+		if rr.ConnectTime == nil {
+			return "-"
+		}
+
+		return rr.ConnectTime.Avg.String()
+	})
+	row("Abort reason", func(rr *RecorderReport) string {
+		if rr.AbortReason == "" {
+			return "-"
+		}
+
+		return rr.AbortReason
+	})
+	row("SLA", func(rr *RecorderReport) string {
+		if rr.SLA == nil {
+			return "-"
+		}
+
+		return rr.SLA.String()
+	})
+
+	return tw.Flush()
+}
@@ -0,0 +1,55 @@
+package rsslap
+
+import (
+	"fmt"
+	"time"
+)
+
+// Time to establish the initial connection of the agents, to tell the connection overhead from the query performance.
+// NOTE: The wait for '--max-connections-total' and the pre-queries are not included
+type ConnectTimeReport struct {
+	Agents int
+	Min    time.Duration
+	Max    time.Duration
+	Avg    time.Duration
+}
+
+func (r *ConnectTimeReport) String() string {
+	return fmt.Sprintf("min=%s max=%s avg=%s (agents=%d)", r.Min, r.Max, r.Avg, r.Agents)
+}
+
+func (rec *Recorder) addConnectTime(d time.Duration) {
+	rec.Lock()
+	defer rec.Unlock()
+	rec.connectTimes = append(rec.connectTimes, d)
+}
+
+// Summarize the connect times, or return nil without them, e.g. with '--pool-size'.
+func (rec *Recorder) connectTimeReport() *ConnectTimeReport {
+	if len(rec.connectTimes) == 0 {
+		return nil
+	}
+
+	r := &ConnectTimeReport{
+		Agents: len(rec.connectTimes),
+		Min:    rec.connectTimes[0],
+		Max:    rec.connectTimes[0],
+	}
+
+	var total time.Duration
+
+	for _, d := range rec.connectTimes {
+		if d < r.Min {
+			r.Min = d
+		}
+
+		if d > r.Max {
+			r.Max = d
+		}
+
+		total += d
+	}
+
+	r.Avg = total / time.Duration(len(rec.connectTimes))
+	return r
+}
@@ -0,0 +1,171 @@
+package rsslap
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/winebarrel/randstr"
+)
+
+const (
+	CopyTimestampLayout = "2006-01-02 15:04:05.999999"
+)
+
+// Generate CopyRows rows as CSV, and return the COPY statement that loads them.
+// NOTE: Without '--copy-s3-prefix' the rows are staged in memory and sent by 'COPY ... FROM STDIN'
+func (data *Data) buildCopyStmt() (string, []interface{}) {
+	cols := []string{}
+
+	if data.PkType == PkTypeNumeric || data.PkType == PkTypeVarchar {
+		cols = append(cols, "id")
+	}
+
+	for i := 1; i <= data.NumberIntCols; i++ {
+		cols = append(cols, fmt.Sprintf("intcol%d", i))
+	}
+
+	for i := 1; i <= data.NumberDecimalCols; i++ {
+		cols = append(cols, fmt.Sprintf("decimalcol%d", i))
+	}
+
+	for i := 1; i <= data.NumberTimestampCols; i++ {
+		cols = append(cols, fmt.Sprintf("tscol%d", i))
+	}
+
+	for i := 1; i <= data.NumberDateCols; i++ {
+		cols = append(cols, fmt.Sprintf("datecol%d", i))
+	}
+
+	for i := 1; i <= data.NumberBoolCols; i++ {
+		cols = append(cols, fmt.Sprintf("boolcol%d", i))
+	}
+
+	for i := 1; i <= data.NumberCharCols; i++ {
+		cols = append(cols, fmt.Sprintf("charcol%d", i))
+	}
+
+	if data.AppendTimestamp {
+		cols = append(cols, AppendTimestampColName)
+	}
+
+	data.copyData.Reset()
+
+	for r := 0; r < data.CopyRows; r++ {
+		data.writeCopyRow(&data.copyData)
+	}
+
+	data.bytesWritten += int64(data.copyData.Len())
+	stmt := "COPY " + AutoGenerateTableName + " (" + strings.Join(cols, ",") + ") "
+
+	if data.CopyS3Prefix == "" {
+		data.copyKey = ""
+		return stmt + "FROM STDIN WITH (FORMAT csv)", []interface{}{}
+	}
+
+	data.copySeq++
+	data.copyKey = fmt.Sprintf("%s/rsslap-%d-%d-%d.csv", strings.TrimSuffix(data.CopyS3Prefix, "/"), data.copyRunId, data.agentId, data.copySeq)
+	stmt += fmt.Sprintf("FROM '%s' IAM_ROLE '%s' CSV", data.copyKey, data.CopyIAMRole)
+
+	// NOTE: Redshift loads the empty VARCHAR fields as empty strings by default
+	if data.NullProbability > 0 {
+		stmt += " EMPTYASNULL"
+	}
+
+	return stmt, []interface{}{}
+}
+
+func (data *Data) writeCopyRow(buf *bytes.Buffer) {
+	sep := false
+	writeSep := func() {
+		if sep {
+			buf.WriteByte(',')
+		}
+
+		sep = true
+	}
+
+	if data.PkType == PkTypeNumeric || data.PkType == PkTypeVarchar {
+		writeSep()
+		buf.WriteString(data.generateKey())
+	}
+
+	// NOTE: An unquoted empty field is NULL
+	writeNullable := func(v string) {
+		writeSep()
+
+		if !data.nextIsNull() {
+			buf.WriteString(v)
+		}
+	}
+
+	for i := 1; i <= data.NumberIntCols; i++ {
+		if data.isPartitionKey(i) {
+			writeSep()
+			buf.WriteString(strconv.FormatInt(data.intColValue(i), 10))
+		} else {
+			writeNullable(strconv.FormatInt(data.intColValue(i), 10))
+		}
+	}
+
+	for i := 1; i <= data.NumberDecimalCols; i++ {
+		writeNullable(data.decimalColValue())
+	}
+
+	if data.NumberTimestampCols > 0 {
+		base := data.timestampColBase()
+
+		for i := 1; i <= data.NumberTimestampCols; i++ {
+			writeNullable(data.timestampColValue(base).Format(CopyTimestampLayout))
+		}
+	}
+
+	for i := 1; i <= data.NumberDateCols; i++ {
+		writeNullable(data.dateColValue())
+	}
+
+	for i := 1; i <= data.NumberBoolCols; i++ {
+		writeNullable(strconv.FormatBool(data.boolColValue()))
+	}
+
+	for i := 1; i <= data.NumberCharCols; i++ {
+		writeNullable(randstr.String(data.randSrc, data.CharColLength))
+	}
+
+	if data.AppendTimestamp {
+		writeSep()
+		buf.WriteString(data.nextAppendTimestamp().Format(CopyTimestampLayout))
+	}
+
+	buf.WriteByte('\n')
+}
+
+// Upload the generated rows to S3 before the COPY statement is timed.
+func (agent *Agent) stageCopyData(ctx context.Context) error {
+	if agent.data.copyKey == "" || agent.taskOps.OnlyPrint {
+		return nil
+	}
+
+	err := putS3Object(ctx, agent.data.copyKey, agent.data.copyData.Bytes())
+
+	if err != nil {
+		return fmt.Errorf("failed to stage COPY data (agent id=%d): %w", agent.id, err)
+	}
+
+	return nil
+}
+
+func (agent *Agent) copyFrom(ctx context.Context, q string) error {
+	pgxConn, ok := agent.db.(*pgx.Conn)
+
+	if !ok {
+		_, err := agent.db.Exec(ctx, q)
+		return err
+	}
+
+	_, err := pgxConn.PgConn().CopyFrom(ctx, bytes.NewReader(agent.data.copyData.Bytes()), q)
+	return err
+}
@@ -0,0 +1,158 @@
+package rsslap
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	// Window of the running percentiles and the agent activity
+	DashboardWindow         = 10 * time.Second
+	DashboardSparklineWidth = 60
+)
+
+var sparklineRunes = []rune("▁▂▃▄▅▆▇█")
+
+// Renders a live view of the test to stderr in place of the progress line.
+type dashboard struct {
+	qpsHist   []float64
+	lineCnt   int
+	termWidth int
+}
+
+// NOTE: Returns nil if stdout is not a terminal, to fall back to the progress line
+func newDashboard() *dashboard {
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		return nil
+	}
+
+	return &dashboard{}
+}
+
+func (d *dashboard) render(task *Task, rec *Recorder, execCnt int, prevExecCnt int, taskStart time.Time, numTermAgents int) {
+	qps := float64(execCnt-prevExecCnt) / ProgressReportPeriod
+	d.qpsHist = append(d.qpsHist, qps)
+
+	if len(d.qpsHist) > DashboardSparklineWidth {
+		d.qpsHist = d.qpsHist[len(d.qpsHist)-DashboardSparklineWidth:]
+	}
+
+	if width, _, err := term.GetSize(0); err == nil {
+		d.termWidth = width
+	}
+
+	resTimes, agentCnts, errCnt := rec.recentStats(DashboardWindow)
+	sort.Slice(resTimes, func(i, j int) bool { return resTimes[i] < resTimes[j] })
+	elapsed := time.Since(taskStart)
+	elapsedLine := fmt.Sprintf("Elapsed:  %s", elapsed.Round(time.Second))
+
+	if phase := task.phase(rec, elapsed); phase != "" {
+		elapsedLine += " (" + phase + ")"
+	}
+
+	lines := []string{
+		elapsedLine,
+		fmt.Sprintf("Agents:   %d running / %d", task.NAgents-numTermAgents, task.NAgents),
+		fmt.Sprintf("Queries:  %d (%.0f qps)", execCnt, qps),
+		fmt.Sprintf("Errors:   %d", errCnt),
+		fmt.Sprintf("Latency:  p50=%s p90=%s p99=%s (last %s)",
+			percentile(resTimes, 50), percentile(resTimes, 90), percentile(resTimes, 99), DashboardWindow),
+		fmt.Sprintf("QPS:      %s", sparkline(d.qpsHist)),
+	}
+
+	// One character per agent by its number of queries in the window
+	activity := make([]float64, task.NAgents)
+
+	for id, cnt := range agentCnts {
+		if id >= 0 && id < len(activity) {
+			activity[id] = float64(cnt)
+		}
+	}
+
+	for len(activity) > 0 {
+		n := len(activity)
+
+		if d.termWidth > 10 && n > d.termWidth-10 {
+			n = d.termWidth - 10
+		}
+
+		label := "          "
+
+		if len(lines) == 6 {
+			label = "Activity: "
+		}
+
+		lines = append(lines, label+sparkline(activity[:n]))
+		activity = activity[n:]
+	}
+
+	sb := strings.Builder{}
+
+	// Move the cursor back to the top of the previous rendering
+	if d.lineCnt > 0 {
+		fmt.Fprintf(&sb, "\r\033[%dA", d.lineCnt)
+	}
+
+	for _, line := range lines {
+		sb.WriteString("\r\033[K" + line + "\n")
+	}
+
+	d.lineCnt = len(lines)
+	fmt.Fprint(os.Stderr, sb.String())
+}
+
+func sparkline(values []float64) string {
+	max := 0.0
+
+	for _, v := range values {
+		max = math.Max(max, v)
+	}
+
+	sb := strings.Builder{}
+
+	for _, v := range values {
+		idx := 0
+
+		if max > 0 {
+			idx = int(v / max * float64(len(sparklineRunes)-1))
+		}
+
+		sb.WriteRune(sparklineRunes[idx])
+	}
+
+	return sb.String()
+}
+
+// Return the response times and the number of queries per agent in the window, and the total number of errors.
+func (rec *Recorder) recentStats(window time.Duration) ([]time.Duration, map[int]int, int) {
+	rec.Lock()
+	defer rec.Unlock()
+	now := time.Now()
+	since := now.Add(-window)
+	resTimes := []time.Duration{}
+	agentCnts := map[int]int{}
+
+	// NOTE: The data points are appended in batches sent every RecordPeriod, so they are almost in time order
+	for i := len(rec.dataPoints) - 1; i >= 0; i-- {
+		v := rec.dataPoints[i]
+
+		if v.timestamp.Before(since.Add(-2 * RecordPeriod)) {
+			break
+		}
+
+		if v.timestamp.Before(since) {
+			continue
+		}
+
+		resTimes = append(resTimes, v.resTime)
+		agentCnts[int(v.agentId)]++
+	}
+
+	return resTimes, agentCnts, len(rec.errorDataPoints)
+}
@@ -1,8 +1,10 @@
 package rsslap
 
 import (
+	"bytes"
 	"fmt"
 	"math/rand"
+	"strconv"
 	"strings"
 	"time"
 
@@ -10,29 +12,112 @@ import (
 )
 
 type AutoGenerateSqlLoadType string
+type PartitionType string
+type DistStyle string
+type MixedSchedule string
+type PkType string
+type QueryDistribution string
 
 const (
-	LoadTypeMixed         = AutoGenerateSqlLoadType("mixed")  // require pre-populated data
-	LoadTypeUpdate        = AutoGenerateSqlLoadType("update") // require pre-populated data
-	LoadTypeWrite         = AutoGenerateSqlLoadType("write")
-	LoadTypeKey           = AutoGenerateSqlLoadType("key")  // require pre-populated data
-	LoadTypeRead          = AutoGenerateSqlLoadType("read") // require pre-populated data
-	AutoGenerateTableName = "t1"
+	LoadTypeMixed               = AutoGenerateSqlLoadType("mixed")  // require pre-populated data
+	LoadTypeUpdate              = AutoGenerateSqlLoadType("update") // require pre-populated data
+	LoadTypeWrite               = AutoGenerateSqlLoadType("write")
+	LoadTypeKey                 = AutoGenerateSqlLoadType("key")    // require pre-populated data
+	LoadTypeRead                = AutoGenerateSqlLoadType("read")   // require pre-populated data
+	LoadTypeDelete              = AutoGenerateSqlLoadType("delete") // require pre-populated data
+	LoadTypeCopy                = AutoGenerateSqlLoadType("copy")
+	LoadTypeAggregate           = AutoGenerateSqlLoadType("aggregate") // require pre-populated data
+	LoadTypeJoin                = AutoGenerateSqlLoadType("join")      // require pre-populated data
+	AutoGenerateTableName       = "t1"
+	MixedScheduleDeterministic  = MixedSchedule("deterministic")
+	MixedScheduleRandom         = MixedSchedule("random")
+	PkTypeBigint                = PkType("bigint")
+	PkTypeNumeric               = PkType("numeric")
+	PkTypeVarchar               = PkType("varchar")
+	PartitionTypeRange          = PartitionType("range")
+	PartitionTypeList           = PartitionType("list")
+	DistStyleEven               = DistStyle("even")
+	DistStyleKey                = DistStyle("key")
+	DistStyleAll                = DistStyle("all")
+	MaxIntColValue              = 1 << 31
+	AppendTimestampColName      = "created_at"
+	AppendLatestRows            = 100
+	CallProcArgTypeInt          = "int"
+	CallProcArgTypeChar         = "char"
+	MaxCharColLength            = 65535 // max VARCHAR length of Redshift
+	MaxDecimalPrecision         = 38    // max NUMERIC precision of Redshift
+	MaxDecimalIntDigits         = 15    // integer digits of the generated DECIMAL values within int64
+	TimestampColType            = "timestamp"
+	DateColLayout               = "2006-01-02"
+	DatePredicateDays           = 7  // width of the date range read by the generated SELECT
+	BoolColToggleRatio          = 10 // 1 in 10 UPDATEs toggles the BOOLEAN columns
+	QueryDistributionAll        = QueryDistribution("all")
+	QueryDistributionSequential = QueryDistribution("sequential")
+	QueryDistributionRoundRobin = QueryDistribution("round-robin")
+	QueryDistributionRandom     = QueryDistribution("random")
+	QueryDistributionWeighted   = QueryDistribution("weighted")
+	DefaultQueryTag             = "default"  // tag of the queries without '-- tag: NAME'
+	InlineQuerySource           = "(inline)" // source of the queries given as a string
+	QueryTypeQueryPrefix        = "query#"   // followed by the 1-based index of '--query(-q)'
 )
 
+// Number of the queries loaded from each '--query(-q)', e.g. each file of a directory.
+type QuerySource struct {
+	Source string
+	Count  int
+}
+
 type DataOpts struct {
 	LoadType               AutoGenerateSqlLoadType
 	GuidPrimary            bool
+	PkType                 PkType
 	NumberSecondaryIndexes int
 	CommitRate             int
 	MixedSelRatio          int
 	MixedInsRatio          int
+	MixedSchedule          MixedSchedule
 	NumberIntCols          int
 	IntColsIndex           bool
+	NumberDecimalCols      int
+	DecimalPrecision       string
+	DecimalColsIndex       bool
+	NumberTimestampCols    int
+	TimestampFrom          time.Time
+	TimestampTo            time.Time
+	TimestampStep          time.Duration
+	NumberDateCols         int
+	DateRangeStart         time.Time
+	DateRangeEnd           time.Time
+	NumberBoolCols         int
 	NumberCharCols         int
+	NumberJoinIntCols      int
+	NumberJoinCharCols     int
+	CharColLength          int
 	CharColsIndex          bool
-	Queries                []string `json:"-"`
+	NullProbability        float64
+	DistStyle              DistStyle
+	Distkey                string
+	Sortkey                []string
+	PartitionBy            PartitionType
+	PartitionKey           string
+	NumberPartitions       int
+	AppendTimestamp        bool
+	CopyS3Prefix           string
+	CopyIAMRole            string `json:"-"`
+	CopyRows               int
+	Queries                []string      `json:"-"`
+	QuerySources           []QuerySource `json:",omitempty"`
+	SQLTemplate            bool
+	ParamFile              string
+	ParamOrder             ParamOrder
+	Params                 *ParamTable `json:"-"`
+	QueryDistribution      QueryDistribution
+	QueryWeights           []int
+	QueryTags              []string `json:",omitempty"`
+	CallProc               string
+	CallProcArgs           []string
 	PreQueries             []string
+	Seed                   int64
 }
 
 type Data struct {
@@ -45,15 +130,89 @@ type Data struct {
 	committed   bool
 	queryIdx    int
 	shuffleList []int
+	templates   []*sqlTemplate
+	// for '{{seq}}' of '--sql-template'
+	templateSeq int64
+	// for '{{col NAME}}' of '--sql-template'
+	paramIdx int
+	paramRow []string
+	// for DECIMAL columns
+	decimalPrecision int
+	decimalScale     int
+	// Kind of the last statement, e.g. "key" or "query#2"
+	queryType string
+	// Kinds of the queries of '--query(-q)', not to format them for each query
+	queryTypes []string
+	// Tag of the last query of '-- tag: NAME', or empty without tags
+	queryTag string
+	// Estimated bytes of the generated rows to be inserted
+	bytesWritten int64
+	// for 'copy' load type
+	agentId   int
+	copyRunId int64
+	copySeq   int
+	copyKey   string
+	copyData  bytes.Buffer
+	// Sequences of the generated rows, shared with the Data of the phases of the agent
+	seq *rowSeq
+}
+
+// Sequences of the generated keys and timestamps of a stream of rows, e.g. of an agent.
+// NOTE: Not shared between the agents, so that the same seed generates the same rows regardless of the scheduling
+type rowSeq struct {
+	// Index of the stream out of the streams, to interleave the sequences of the streams without duplicates
+	stream  int64
+	streams int64
+	// Number of the rows generated so far
+	timestampCol int64
+	key          int64
+	// Microseconds of the last timestamp of the 'append' load type
+	lastAppendTimestamp int64
+}
+
+// Return the next number of the sequence, unique across the streams.
+func (seq *rowSeq) next(counter *int64) int64 {
+	n := *counter*seq.streams + seq.stream
+	*counter++
+	return n
 }
 
-func newData(opts *DataOpts, idList []string) (data *Data) {
-	shuffleList := rand.Perm(len(opts.Queries))
+// NOTE: The same seed generates the same data and the same query sequence
+func newData(opts *DataOpts, idList []string, seed int64) (data *Data) {
+	randSrc := rand.NewSource(seed)
 	data = &Data{
 		DataOpts:    opts,
-		randSrc:     rand.NewSource(time.Now().UnixNano()),
+		randSrc:     randSrc,
+		copyRunId:   opts.Seed, // NOTE: From the current time without '--seed'
+		seq:         &rowSeq{streams: 1},
 		idList:      idList,
-		shuffleList: shuffleList,
+		shuffleList: rand.New(randSrc).Perm(len(opts.Queries)),
+		queryTypes:  make([]string, len(opts.Queries)),
+	}
+
+	for i := range data.queryTypes {
+		data.queryTypes[i] = QueryTypeQueryPrefix + strconv.Itoa(i+1)
+	}
+
+	if opts.NumberDecimalCols > 0 {
+		var err error
+		data.decimalPrecision, data.decimalScale, err = ParseDecimalPrecision(opts.DecimalPrecision)
+
+		if err != nil {
+			panic("Failed to parse DECIMAL precision: " + err.Error())
+		}
+	}
+
+	if opts.SQLTemplate {
+		for _, q := range opts.Queries {
+			tmpl, err := parseSQLTemplate(q)
+
+			if err != nil {
+				panic("Failed to parse SQL template: " + err.Error())
+			}
+
+			data.templates = append(data.templates, tmpl)
+		}
 	}
 
 	return
@@ -62,10 +221,6 @@ func newData(opts *DataOpts, idList []string) (data *Data) {
 func (data *Data) initStmts() []string {
 	stmts := []string{}
 
-	if len(data.PreQueries) > 0 {
-		stmts = append(stmts, data.PreQueries...)
-	}
-
 	if data.CommitRate > 0 {
 		stmts = append(stmts, "BEGIN")
 	}
@@ -78,11 +233,13 @@ func (data *Data) next() (string, []interface{}) {
 		if data.commitCnt == data.CommitRate {
 			data.commitCnt = 0
 			data.committed = true
+			data.queryType = "commit"
 			return "COMMIT", []interface{}{}
 		}
 
 		if data.committed {
 			data.committed = false
+			data.queryType = "begin"
 			return "BEGIN", []interface{}{}
 		}
 
@@ -90,30 +247,45 @@ func (data *Data) next() (string, []interface{}) {
 	}
 
 	if len(data.Queries) > 0 {
-		q := data.Queries[data.shuffleList[data.queryIdx]]
-		data.queryIdx++
+		idx := data.nextQueryIdx()
+		data.queryType = data.queryTypes[idx]
 
-		if data.queryIdx == len(data.Queries) {
-			data.queryIdx = 0
+		if data.QueryTags != nil {
+			data.queryTag = data.QueryTags[idx]
+		}
+
+		if data.templates != nil {
+			return data.templates[idx].expand(data), []interface{}{}
 		}
 
-		return q, []interface{}{}
+		return data.Queries[idx], []interface{}{}
 	}
 
+	if data.CallProc != "" {
+		data.queryType = "call"
+		return data.buildCallStmt()
+	}
+
+	data.queryType = string(data.LoadType)
+
 	switch data.LoadType {
 	case LoadTypeMixed:
 		var stmt string
 		var args []interface{}
-		if data.mixedIdx < data.MixedSelRatio {
-			stmt, args = data.buildSelectStmt(true)
-		} else {
-			stmt, args = data.buildInsertStmt()
-		}
+		if data.nextMixedIsSelect() {
+			// NOTE: With DATE columns, the SELECT reads a date range instead of looking up a key
+			key := data.NumberDateCols == 0
 
-		data.mixedIdx++
+			if key {
+				data.queryType = string(LoadTypeKey)
+			} else {
+				data.queryType = string(LoadTypeRead)
+			}
 
-		if data.mixedIdx >= data.MixedSelRatio+data.MixedInsRatio {
-			data.mixedIdx = 0
+			stmt, args = data.buildSelectStmt(key)
+		} else {
+			data.queryType = string(LoadTypeWrite)
+			stmt, args = data.buildInsertStmt()
 		}
 
 		return stmt, args
@@ -125,20 +297,112 @@ func (data *Data) next() (string, []interface{}) {
 		return data.buildSelectStmt(true)
 	case LoadTypeRead:
 		return data.buildSelectStmt(false)
+	case LoadTypeDelete:
+		return data.buildDeleteStmt()
+	case LoadTypeCopy:
+		return data.buildCopyStmt()
+	case LoadTypeJoin:
+		return data.buildJoinStmt()
+	case LoadTypeAggregate:
+		// NOTE: Interleave INSERT by the same ratio as 'mixed' load type
+		if data.nextMixedIsSelect() {
+			return data.buildAggregateStmt()
+		}
+
+		data.queryType = string(LoadTypeWrite)
+		return data.buildInsertStmt()
 	default:
 		panic("Failed to generate SQL statement: invalid load type: " + data.LoadType)
 	}
 }
 
+// Start a new transaction with the next statement after the current one is rolled back.
+func (data *Data) rollback() {
+	data.commitCnt = 0
+	data.committed = true
+}
+
+// Index of the next custom query by the query distribution.
+func (data *Data) nextQueryIdx() int {
+	switch data.QueryDistribution {
+	case QueryDistributionRoundRobin:
+		// NOTE: Agent i always executes query i % N
+		return data.agentId % len(data.Queries)
+	case QueryDistributionSequential:
+		// NOTE: Each agent runs the queries in the given order, e.g. when a query reads the rows written by the previous one
+		idx := data.queryIdx
+		data.queryIdx = (data.queryIdx + 1) % len(data.Queries)
+		return idx
+	case QueryDistributionRandom:
+		return int(data.randSrc.Int63() % int64(len(data.Queries)))
+	case QueryDistributionWeighted:
+		return data.nextWeightedQueryIdx()
+	default:
+		// Execute all queries in a shuffled order
+		idx := data.shuffleList[data.queryIdx]
+		data.queryIdx++
+
+		if data.queryIdx == len(data.Queries) {
+			data.queryIdx = 0
+		}
+
+		return idx
+	}
+}
+
+// Pick a query at random in proportion to its weight.
+func (data *Data) nextWeightedQueryIdx() int {
+	total := 0
+
+	for _, w := range data.QueryWeights {
+		total += w
+	}
+
+	r := int(data.randSrc.Int63() % int64(total))
+
+	for i, w := range data.QueryWeights {
+		if r < w {
+			return i
+		}
+
+		r -= w
+	}
+
+	return len(data.QueryWeights) - 1
+}
+
+func (data *Data) nextMixedIsSelect() bool {
+	if data.MixedSchedule == MixedScheduleRandom {
+		return data.randSrc.Int63()%int64(data.MixedSelRatio+data.MixedInsRatio) < int64(data.MixedSelRatio)
+	}
+
+	isSelect := data.mixedIdx < data.MixedSelRatio
+	data.mixedIdx++
+
+	if data.mixedIdx >= data.MixedSelRatio+data.MixedInsRatio {
+		data.mixedIdx = 0
+	}
+
+	return isSelect
+}
+
 func (data *Data) buildCreateTableStmt() (string, []string) {
 	indices := []string{}
 	sb := strings.Builder{}
-	sb.WriteString("CREATE TABLE " + AutoGenerateTableName + " (id bigint ")
+	sb.WriteString("CREATE TABLE " + AutoGenerateTableName + " (id ")
+	pkConstraint := " PRIMARY KEY"
+
+	// NOTE: The primary key of a partitioned table must include the partition key
+	if data.PartitionBy != "" {
+		pkConstraint = ""
+	}
 
 	if data.GuidPrimary {
-		sb.WriteString("uuid PRIMARY KEY DEFAULT gen_random_uuid()")
+		sb.WriteString(data.pkColType() + pkConstraint + " DEFAULT gen_random_uuid()")
+	} else if data.PkType == PkTypeNumeric || data.PkType == PkTypeVarchar {
+		sb.WriteString(data.pkColType() + pkConstraint)
 	} else {
-		sb.WriteString("generated by default as identity(1,1) PRIMARY KEY")
+		sb.WriteString("bigint generated by default as identity(1,1)" + pkConstraint)
 	}
 
 	for i := 1; i <= data.NumberSecondaryIndexes; i++ {
@@ -153,19 +417,134 @@ func (data *Data) buildCreateTableStmt() (string, []string) {
 		}
 	}
 
+	for i := 1; i <= data.NumberDecimalCols; i++ {
+		fmt.Fprintf(&sb, ",decimalcol%d numeric(%d,%d)", i, data.decimalPrecision, data.decimalScale)
+
+		if data.DecimalColsIndex {
+			indices = append(indices, fmt.Sprintf("CREATE INDEX ON "+AutoGenerateTableName+"(decimalcol%d)", i))
+		}
+	}
+
+	for i := 1; i <= data.NumberTimestampCols; i++ {
+		fmt.Fprintf(&sb, ",tscol%d %s", i, TimestampColType)
+	}
+
+	for i := 1; i <= data.NumberDateCols; i++ {
+		fmt.Fprintf(&sb, ",datecol%d date", i)
+	}
+
+	for i := 1; i <= data.NumberBoolCols; i++ {
+		fmt.Fprintf(&sb, ",boolcol%d boolean", i)
+	}
+
 	for i := 1; i <= data.NumberCharCols; i++ {
-		fmt.Fprintf(&sb, ",charcol%d varchar(128)", i)
+		fmt.Fprintf(&sb, ",charcol%d varchar(%d)", i, data.CharColLength)
 
 		if data.CharColsIndex {
 			indices = append(indices, fmt.Sprintf("CREATE INDEX ON "+AutoGenerateTableName+"(charcol%d)", i))
 		}
 	}
 
-	sb.WriteString(")")
+	if data.AppendTimestamp {
+		sb.WriteString("," + AppendTimestampColName + " timestamp")
+	}
+
+	if data.PartitionBy != "" {
+		fmt.Fprintf(&sb, ",PRIMARY KEY (id,%s)) PARTITION BY %s (%s)", data.PartitionKey, strings.ToUpper(string(data.PartitionBy)), data.PartitionKey)
+	} else {
+		sb.WriteString(")")
+	}
+
+	if data.DistStyle != "" {
+		sb.WriteString(" DISTSTYLE " + strings.ToUpper(string(data.DistStyle)))
+	}
+
+	if data.Distkey != "" {
+		fmt.Fprintf(&sb, " DISTKEY(%s)", data.Distkey)
+	}
+
+	if len(data.Sortkey) > 0 {
+		fmt.Fprintf(&sb, " SORTKEY(%s)", strings.Join(data.Sortkey, ","))
+	}
 
 	return sb.String(), indices
 }
 
+// Names of the columns of the table to be created.
+func (dataOpts *DataOpts) GeneratedColumns() []string {
+	cols := []string{"id"}
+
+	for i := 1; i <= dataOpts.NumberSecondaryIndexes; i++ {
+		cols = append(cols, fmt.Sprintf("id%d", i))
+	}
+
+	for i := 1; i <= dataOpts.NumberIntCols; i++ {
+		cols = append(cols, fmt.Sprintf("intcol%d", i))
+	}
+
+	for i := 1; i <= dataOpts.NumberDecimalCols; i++ {
+		cols = append(cols, fmt.Sprintf("decimalcol%d", i))
+	}
+
+	for i := 1; i <= dataOpts.NumberTimestampCols; i++ {
+		cols = append(cols, fmt.Sprintf("tscol%d", i))
+	}
+
+	for i := 1; i <= dataOpts.NumberDateCols; i++ {
+		cols = append(cols, fmt.Sprintf("datecol%d", i))
+	}
+
+	for i := 1; i <= dataOpts.NumberBoolCols; i++ {
+		cols = append(cols, fmt.Sprintf("boolcol%d", i))
+	}
+
+	for i := 1; i <= dataOpts.NumberCharCols; i++ {
+		cols = append(cols, fmt.Sprintf("charcol%d", i))
+	}
+
+	if dataOpts.AppendTimestamp {
+		cols = append(cols, AppendTimestampColName)
+	}
+
+	return cols
+}
+
+func (data *Data) buildCreatePartitionStmts() []string {
+	stmts := []string{}
+
+	if data.PartitionBy == "" {
+		return stmts
+	}
+
+	for i := 0; i < data.NumberPartitions; i++ {
+		var bound string
+
+		switch data.PartitionBy {
+		case PartitionTypeRange:
+			from := "MINVALUE"
+			to := "MAXVALUE"
+
+			if i > 0 {
+				from = fmt.Sprint(MaxIntColValue / data.NumberPartitions * i)
+			}
+
+			if i < data.NumberPartitions-1 {
+				to = fmt.Sprint(MaxIntColValue / data.NumberPartitions * (i + 1))
+			}
+
+			bound = fmt.Sprintf("FROM (%s) TO (%s)", from, to)
+		case PartitionTypeList:
+			bound = fmt.Sprintf("IN (%d)", i)
+		default:
+			panic("Failed to generate SQL statement: invalid partition type: " + data.PartitionBy)
+		}
+
+		stmts = append(stmts, fmt.Sprintf("CREATE TABLE %s_p%d PARTITION OF %s FOR VALUES %s", AutoGenerateTableName, i, AutoGenerateTableName, bound))
+	}
+
+	return stmts
+}
+
 func (data *Data) buildSelectStmt(key bool) (string, []interface{}) {
 	args := []interface{}{}
 	sb := strings.Builder{}
@@ -179,11 +558,43 @@ func (data *Data) buildSelectStmt(key bool) (string, []interface{}) {
 		fmt.Fprintf(&sb, "intcol%d", i)
 	}
 
-	for i := 1; i <= data.NumberCharCols; i++ {
+	for i := 1; i <= data.NumberDecimalCols; i++ {
 		if data.NumberIntCols >= 1 || i >= 2 {
 			sb.WriteString(",")
 		}
 
+		fmt.Fprintf(&sb, "decimalcol%d", i)
+	}
+
+	for i := 1; i <= data.NumberTimestampCols; i++ {
+		if data.NumberIntCols+data.NumberDecimalCols >= 1 || i >= 2 {
+			sb.WriteString(",")
+		}
+
+		fmt.Fprintf(&sb, "tscol%d", i)
+	}
+
+	for i := 1; i <= data.NumberDateCols; i++ {
+		if data.NumberIntCols+data.NumberDecimalCols+data.NumberTimestampCols >= 1 || i >= 2 {
+			sb.WriteString(",")
+		}
+
+		fmt.Fprintf(&sb, "datecol%d", i)
+	}
+
+	for i := 1; i <= data.NumberBoolCols; i++ {
+		if data.NumberIntCols+data.NumberDecimalCols+data.NumberTimestampCols+data.NumberDateCols >= 1 || i >= 2 {
+			sb.WriteString(",")
+		}
+
+		fmt.Fprintf(&sb, "boolcol%d", i)
+	}
+
+	for i := 1; i <= data.NumberCharCols; i++ {
+		if data.NumberIntCols+data.NumberDecimalCols+data.NumberTimestampCols+data.NumberDateCols+data.NumberBoolCols >= 1 || i >= 2 {
+			sb.WriteString(",")
+		}
+
 		fmt.Fprintf(&sb, "charcol%d", i)
 	}
 
@@ -192,16 +603,60 @@ func (data *Data) buildSelectStmt(key bool) (string, []interface{}) {
 	if key {
 		fmt.Fprintf(&sb, " WHERE id = $1")
 		args = append(args, data.nextId())
+	} else if data.NumberDateCols > 0 {
+		// NOTE: Read a random range of the first DATE column, e.g. to measure the zone map pruning
+		from, to := data.dateRangePredicate()
+		sb.WriteString(" WHERE datecol1 BETWEEN $1 AND $2")
+		args = append(args, from, to)
+	}
+
+	if !key && data.AppendTimestamp {
+		fmt.Fprintf(&sb, " ORDER BY %s DESC LIMIT %d", AppendTimestampColName, AppendLatestRows)
 	}
 
 	return sb.String(), args
 }
 
+// Aggregate the numeric columns by the first INT column, in a random date range of the first DATE column if any.
+func (data *Data) buildAggregateStmt() (string, []interface{}) {
+	args := []interface{}{}
+	sb := strings.Builder{}
+	sb.WriteString("SELECT intcol1,COUNT(*)")
+
+	for i := 2; i <= data.NumberIntCols; i++ {
+		fmt.Fprintf(&sb, ",SUM(intcol%d),AVG(intcol%d)", i, i)
+	}
+
+	for i := 1; i <= data.NumberDecimalCols; i++ {
+		fmt.Fprintf(&sb, ",SUM(decimalcol%d),AVG(decimalcol%d)", i, i)
+	}
+
+	sb.WriteString(" FROM " + AutoGenerateTableName)
+
+	if data.NumberDateCols > 0 {
+		from, to := data.dateRangePredicate()
+		sb.WriteString(" WHERE datecol1 BETWEEN $1 AND $2")
+		args = append(args, from, to)
+	}
+
+	sb.WriteString(" GROUP BY intcol1")
+
+	return sb.String(), args
+}
+
 func (data *Data) buildInsertStmt() (string, []interface{}) {
 	args := []interface{}{}
 	phIdx := 1
 	sb := strings.Builder{}
-	sb.WriteString("INSERT INTO " + AutoGenerateTableName + " VALUES (DEFAULT")
+	sb.WriteString("INSERT INTO " + AutoGenerateTableName + " VALUES (")
+
+	if data.PkType == PkTypeNumeric || data.PkType == PkTypeVarchar {
+		fmt.Fprintf(&sb, "$%d", phIdx)
+		phIdx++
+		args = append(args, data.generateKey())
+	} else {
+		sb.WriteString("DEFAULT")
+	}
 
 	for i := 1; i <= data.NumberSecondaryIndexes; i++ {
 		sb.WriteString(",gen_random_uuid()")
@@ -210,21 +665,86 @@ func (data *Data) buildInsertStmt() (string, []interface{}) {
 	for i := 1; i <= data.NumberIntCols; i++ {
 		fmt.Fprintf(&sb, ",$%d", phIdx)
 		phIdx++
-		num := data.randSrc.Int63() >> 32
-		args = append(args, num)
+
+		if data.isPartitionKey(i) {
+			args = append(args, data.intColValue(i))
+		} else {
+			args = append(args, data.nullable(data.intColValue(i)))
+		}
+	}
+
+	for i := 1; i <= data.NumberDecimalCols; i++ {
+		fmt.Fprintf(&sb, ",$%d", phIdx)
+		phIdx++
+		args = append(args, data.nullable(data.decimalColValue()))
+	}
+
+	if data.NumberTimestampCols > 0 {
+		base := data.timestampColBase()
+
+		for i := 1; i <= data.NumberTimestampCols; i++ {
+			fmt.Fprintf(&sb, ",$%d", phIdx)
+			phIdx++
+			args = append(args, data.nullable(data.timestampColValue(base)))
+		}
+	}
+
+	for i := 1; i <= data.NumberDateCols; i++ {
+		fmt.Fprintf(&sb, ",$%d", phIdx)
+		phIdx++
+		args = append(args, data.nullable(data.dateColValue()))
+	}
+
+	for i := 1; i <= data.NumberBoolCols; i++ {
+		fmt.Fprintf(&sb, ",$%d", phIdx)
+		phIdx++
+		args = append(args, data.nullable(data.boolColValue()))
 	}
 
 	for i := 1; i <= data.NumberCharCols; i++ {
 		fmt.Fprintf(&sb, ",$%d", phIdx)
 		phIdx++
-		args = append(args, randstr.String(data.randSrc, 128))
+		args = append(args, data.nullable(randstr.String(data.randSrc, data.CharColLength)))
+	}
+
+	if data.AppendTimestamp {
+		fmt.Fprintf(&sb, ",$%d", phIdx)
+		phIdx++
+		args = append(args, data.nextAppendTimestamp())
 	}
 
 	sb.WriteString(")")
+	data.bytesWritten += data.estimateRowSize(args)
 
 	return sb.String(), args
 }
 
+// Estimate the size of a row from the values of the generated columns.
+func (data *Data) estimateRowSize(args []interface{}) int64 {
+	size := int64(16 * data.NumberSecondaryIndexes)
+
+	if data.GuidPrimary {
+		size += 16
+	} else if data.PkType == PkTypeBigint || data.PkType == "" {
+		size += 8
+	}
+
+	for _, v := range args {
+		switch v := v.(type) {
+		case int64:
+			size += 4
+		case string:
+			size += int64(len(v))
+		case time.Time:
+			size += 8
+		case bool:
+			size++
+		}
+	}
+
+	return size
+}
+
 func (data *Data) buildUpdateStmt() (string, []interface{}) {
 	args := []interface{}{}
 	phIdx := 1
@@ -238,18 +758,34 @@ func (data *Data) buildUpdateStmt() (string, []interface{}) {
 
 		fmt.Fprintf(&sb, "intcol%d = $%d", i, phIdx)
 		phIdx++
-		v := data.randSrc.Int63() >> 32
-		args = append(args, v)
+		args = append(args, data.intColValue(i))
 	}
 
-	for i := 1; i <= data.NumberCharCols; i++ {
+	for i := 1; i <= data.NumberDecimalCols; i++ {
 		if data.NumberIntCols >= 1 || i >= 2 {
 			sb.WriteString(",")
 		}
 
+		fmt.Fprintf(&sb, "decimalcol%d = $%d", i, phIdx)
+		phIdx++
+		args = append(args, data.decimalColValue())
+	}
+
+	for i := 1; i <= data.NumberCharCols; i++ {
+		if data.NumberIntCols+data.NumberDecimalCols >= 1 || i >= 2 {
+			sb.WriteString(",")
+		}
+
 		fmt.Fprintf(&sb, "charcol%d = $%d", i, phIdx)
 		phIdx++
-		args = append(args, randstr.String(data.randSrc, 128))
+		args = append(args, randstr.String(data.randSrc, data.CharColLength))
+	}
+
+	// NOTE: Toggle the BOOLEAN columns only occasionally to exercise the updates of the low-cardinality columns
+	if data.NumberBoolCols > 0 && data.randSrc.Int63()%BoolColToggleRatio == 0 {
+		for i := 1; i <= data.NumberBoolCols; i++ {
+			fmt.Fprintf(&sb, ",boolcol%d = NOT boolcol%d", i, i)
+		}
 	}
 
 	fmt.Fprintf(&sb, " WHERE id = $%d", phIdx)
@@ -258,6 +794,223 @@ func (data *Data) buildUpdateStmt() (string, []interface{}) {
 	return sb.String(), args
 }
 
+func (data *Data) buildDeleteStmt() (string, []interface{}) {
+	return "DELETE FROM " + AutoGenerateTableName + " WHERE id = $1", []interface{}{data.nextId()}
+}
+
+func (data *Data) buildCallStmt() (string, []interface{}) {
+	args := []interface{}{}
+	sb := strings.Builder{}
+	sb.WriteString("CALL " + data.CallProc + "(")
+
+	for i, argType := range data.CallProcArgs {
+		if i >= 1 {
+			sb.WriteString(",")
+		}
+
+		fmt.Fprintf(&sb, "$%d", i+1)
+
+		switch argType {
+		case CallProcArgTypeInt:
+			args = append(args, data.intColValue(i+1))
+		case CallProcArgTypeChar:
+			args = append(args, randstr.String(data.randSrc, data.CharColLength))
+		default:
+			panic("Failed to generate CALL statement: invalid argument type: " + argType)
+		}
+	}
+
+	sb.WriteString(")")
+
+	return sb.String(), args
+}
+
+func (data *Data) intColValue(i int) int64 {
+	// Route rows of a list-partitioned table to the existing partitions
+	if data.PartitionBy == PartitionTypeList && data.isPartitionKey(i) {
+		return data.randSrc.Int63() % int64(data.NumberPartitions)
+	}
+
+	return data.randSrc.Int63() >> 32
+}
+
+// Parse the "PRECISION,SCALE" of the DECIMAL columns, e.g. "18,4". The scale is zero if omitted.
+func ParseDecimalPrecision(s string) (int, int, error) {
+	ps := strings.SplitN(s, ",", 2)
+	precision, err := strconv.Atoi(strings.TrimSpace(ps[0]))
+
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid precision: %s", s)
+	}
+
+	scale := 0
+
+	if len(ps) == 2 {
+		scale, err = strconv.Atoi(strings.TrimSpace(ps[1]))
+
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid scale: %s", s)
+		}
+	}
+
+	if precision < 1 || precision > MaxDecimalPrecision {
+		return 0, 0, fmt.Errorf("precision must be between 1 and %d: %s", MaxDecimalPrecision, s)
+	} else if scale < 0 || scale > precision {
+		return 0, 0, fmt.Errorf("scale must be between 0 and the precision: %s", s)
+	}
+
+	return precision, scale, nil
+}
+
+// Generate a value of the DECIMAL columns with the digits of the scale, e.g. "12345.6789".
+// NOTE: The magnitude is log-uniform up to the precision, so that small amounts are as common as large ones
+func (data *Data) decimalColValue() string {
+	r := data.randSrc.Int63()
+	intDigits := data.decimalPrecision - data.decimalScale
+
+	if intDigits > MaxDecimalIntDigits {
+		intDigits = MaxDecimalIntDigits
+	}
+
+	magnitude := int64(1)
+
+	for i := r % int64(intDigits+1); i > 0; i-- {
+		magnitude *= 10
+	}
+
+	value := strconv.FormatInt((r>>8)%magnitude, 10)
+
+	if data.decimalScale == 0 {
+		return value
+	}
+
+	frac := make([]byte, data.decimalScale)
+
+	for i := range frac {
+		frac[i] = byte('0' + data.randSrc.Int63()%10)
+	}
+
+	return value + "." + string(frac)
+}
+
+// Set the stream of the sequences of the generated rows out of the given number of streams, e.g. one for each agent.
+func (data *Data) setSeqStream(stream int, streams int) {
+	data.seq.stream = int64(stream)
+	data.seq.streams = int64(streams)
+}
+
+// Return the next time of the TIMESTAMP columns, which advances by TimestampStep with each row interleaved across
+// all agents to mimic the ingest order, and wraps around at the end of the range.
+func (data *Data) timestampColBase() time.Time {
+	n := data.seq.next(&data.seq.timestampCol)
+	span := data.TimestampTo.Sub(data.TimestampFrom)
+	offset := time.Duration(n) * data.TimestampStep
+
+	if span > 0 {
+		offset %= span
+	} else {
+		offset = 0
+	}
+
+	return data.TimestampFrom.Add(offset)
+}
+
+// Generate a value of the TIMESTAMP columns, jittered within the step from the base time of the row.
+func (data *Data) timestampColValue(base time.Time) time.Time {
+	jitter := time.Duration(0)
+
+	if data.TimestampStep >= time.Microsecond {
+		jitter = time.Duration(data.randSrc.Int63() % int64(data.TimestampStep))
+	}
+
+	return base.Add(jitter).Truncate(time.Microsecond).UTC()
+}
+
+// Generate a value of the DATE columns, uniformly distributed within the date range.
+func (data *Data) dateColValue() string {
+	return data.randomDate(data.DateRangeStart, data.DateRangeEnd).Format(DateColLayout)
+}
+
+func (data *Data) boolColValue() bool {
+	return data.randSrc.Int63()&1 == 1
+}
+
+// Return a random range of DatePredicateDays days within the date range for the generated SELECT.
+func (data *Data) dateRangePredicate() (string, string) {
+	last := data.DateRangeEnd.AddDate(0, 0, -(DatePredicateDays - 1))
+
+	if last.Before(data.DateRangeStart) {
+		last = data.DateRangeStart
+	}
+
+	from := data.randomDate(data.DateRangeStart, last)
+	return from.Format(DateColLayout), from.AddDate(0, 0, DatePredicateDays-1).Format(DateColLayout)
+}
+
+func (data *Data) randomDate(start time.Time, end time.Time) time.Time {
+	days := int64(end.Sub(start)/(24*time.Hour)) + 1
+	return start.AddDate(0, 0, int(data.randSrc.Int63()%days))
+}
+
+// NOTE: The partition key is never NULL so that the rows are routed to the partitions
+func (data *Data) isPartitionKey(i int) bool {
+	return data.PartitionBy != "" && data.PartitionKey == fmt.Sprintf("intcol%d", i)
+}
+
+// Decide whether to generate NULL instead of the next column value by '--null-probability'.
+// NOTE: No random number is drawn if it is zero, so that the same seed generates the same data
+func (data *Data) nextIsNull() bool {
+	return data.NullProbability > 0 && float64(data.randSrc.Int63())/(1<<63) < data.NullProbability
+}
+
+func (data *Data) nullable(v interface{}) interface{} {
+	if data.nextIsNull() {
+		return nil
+	}
+
+	return v
+}
+
+// Generate a UUID from the random source, so that the same seed generates the same UUIDs.
+func (data *Data) randUUID() string {
+	var b [16]byte
+
+	// NOTE: 7 bytes of each 63-bit random number
+	for i := 0; i < len(b); i += 7 {
+		r := data.randSrc.Int63()
+
+		for j := i; j < i+7 && j < len(b); j++ {
+			b[j] = byte(r)
+			r >>= 8
+		}
+	}
+
+	return formatUUID(b)
+}
+
+// Generate a unique key of the non-integer primary key type.
+func (data *Data) generateKey() string {
+	n := data.seq.next(&data.seq.key) + 1
+
+	if data.PkType == PkTypeNumeric {
+		return fmt.Sprintf("%d.%04d", n, data.randSrc.Int63()%10000)
+	}
+
+	return fmt.Sprintf("%s-%d", randstr.String(data.randSrc, 16), n)
+}
+
+// Return a timestamp that increases with each call of the agent.
+func (data *Data) nextAppendTimestamp() time.Time {
+	ts := time.Now().UnixNano() / int64(time.Microsecond)
+
+	if ts <= data.seq.lastAppendTimestamp {
+		ts = data.seq.lastAppendTimestamp + 1
+	}
+
+	data.seq.lastAppendTimestamp = ts
+	return time.Unix(0, ts*int64(time.Microsecond)).UTC()
+}
+
 func (data *Data) nextId() string {
 	if data.idIdx >= len(data.idList) {
 		data.idIdx = 0
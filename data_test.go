@@ -0,0 +1,104 @@
+package rsslap
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// Indexes of the queries picked by each agent over '--number-queries' queries.
+func TestNextQueryIdx(t *testing.T) {
+	queries := []string{"select 1", "select 2", "select 3"}
+
+	tests := []struct {
+		name          string
+		distribution  QueryDistribution
+		agentId       int
+		numberQueries int
+		want          []int
+	}{
+		{"sequential from index 0", QueryDistributionSequential, 0, 3, []int{0, 1, 2}},
+		{"sequential wraps around", QueryDistributionSequential, 0, 7, []int{0, 1, 2, 0, 1, 2, 0}},
+		{"sequential of another agent also from index 0", QueryDistributionSequential, 2, 4, []int{0, 1, 2, 0}},
+		{"round-robin of agent 0", QueryDistributionRoundRobin, 0, 3, []int{0, 0, 0}},
+		{"round-robin of agent 4", QueryDistributionRoundRobin, 4, 3, []int{1, 1, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := newData(&DataOpts{Queries: queries, QueryDistribution: tt.distribution}, nil, 1)
+			data.agentId = tt.agentId
+			got := make([]int, tt.numberQueries)
+
+			for i := range got {
+				got[i] = data.nextQueryIdx()
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("nextQueryIdx() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// 'all' runs each query once in each pass through the queries, in the same shuffled order.
+func TestNextQueryIdxAll(t *testing.T) {
+	queries := []string{"select 1", "select 2", "select 3", "select 4", "select 5"}
+	data := newData(&DataOpts{Queries: queries, QueryDistribution: QueryDistributionAll}, nil, 1)
+	var first []int
+
+	for pass := 0; pass < 3; pass++ {
+		got := make([]int, len(queries))
+
+		for i := range got {
+			got[i] = data.nextQueryIdx()
+		}
+
+		if pass == 0 {
+			first = got
+		} else if !reflect.DeepEqual(got, first) {
+			t.Errorf("pass %d = %v, want %v", pass+1, got, first)
+		}
+
+		sorted := append([]int(nil), got...)
+		sort.Ints(sorted)
+
+		if !reflect.DeepEqual(sorted, []int{0, 1, 2, 3, 4}) {
+			t.Errorf("pass %d = %v, want each query once", pass+1, got)
+		}
+	}
+}
+
+// The generated keys depend only on the seed and the stream, not on the other agents, and never collide.
+func TestGenerateKey(t *testing.T) {
+	opts := &DataOpts{PkType: PkTypeNumeric}
+	keys := func(stream int, n int) []string {
+		data := newData(opts, nil, int64(stream))
+		data.setSeqStream(stream, 4)
+		got := make([]string, n)
+
+		for i := range got {
+			got[i] = data.generateKey()
+		}
+
+		return got
+	}
+
+	seen := map[string]bool{}
+
+	for stream := 0; stream < 4; stream++ {
+		got := keys(stream, 100)
+
+		if again := keys(stream, 100); !reflect.DeepEqual(got, again) {
+			t.Errorf("stream %d = %v, want %v with the same seed", stream, again, got)
+		}
+
+		for _, k := range got {
+			if seen[k] {
+				t.Errorf("duplicate key %s of stream %d", k, stream)
+			}
+
+			seen[k] = true
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package rsslap
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgconn"
+)
+
+const (
+	DefaultMaxErrorDetail = 20
+	ErrorMessageMaxLen    = 200
+)
+
+// Names of the common SQLSTATE codes, to tell the kinds of errors apart in the report.
+var sqlStateNames = map[string]string{
+	"08000": "connection_exception",
+	"08003": "connection_does_not_exist",
+	"08006": "connection_failure",
+	"23505": "unique_violation",
+	"25P02": "in_failed_sql_transaction",
+	"40001": "serialization_failure",
+	"40P01": "deadlock_detected",
+	"42601": "syntax_error",
+	"42703": "undefined_column",
+	"42P01": "undefined_table",
+	"53100": "disk_full",
+	"53200": "out_of_memory",
+	"53300": "too_many_connections",
+	"57014": "query_canceled",
+	"57P01": "admin_shutdown",
+	"XX000": "internal_error",
+}
+
+// Failed queries with the same error code and message.
+type ErrorDetail struct {
+	Code           string
+	Name           string `json:",omitempty"`
+	Message        string
+	Count          int
+	FirstAt        time.Time
+	FirstQueryType string
+	FirstQueryIdx  int
+}
+
+// Message of the error without the SQLSTATE, truncated to ErrorMessageMaxLen.
+func errorMessage(err error) string {
+	msg := err.Error()
+	var pgErr *pgconn.PgError
+
+	if errors.As(err, &pgErr) {
+		msg = pgErr.Message
+	}
+
+	if utf8.RuneCountInString(msg) > ErrorMessageMaxLen {
+		msg = string([]rune(msg)[:ErrorMessageMaxLen]) + "..."
+	}
+
+	return msg
+}
+
+// Return the index of the kind of the error by the code and the message.
+// NOTE: The messages are only kept here, not in each data point
+func (rec *Recorder) errorKindIndex(code string, msg string) int32 {
+	return rec.errorKinds.index(code + "\x00" + msg)
+}
+
+// Return the code and the message of the kind of the error.
+func (rec *Recorder) errorKind(idx int32) (string, string) {
+	sig := strings.SplitN(rec.errorKinds.get(idx), "\x00", 2)
+	return sig[0], sig[1]
+}
+
+// Aggregate the failed queries by the error code and message, keeping the first maxDetail signatures.
+// Return the details in descending order of count and the number of the errors of the dropped signatures.
+func (rec *Recorder) errorDetails(maxDetail int) ([]ErrorDetail, int) {
+	recDps := make([]recorderDataPoint, len(rec.errorDataPoints))
+	copy(recDps, rec.errorDataPoints)
+	sort.SliceStable(recDps, func(i, j int) bool { return recDps[i].timestamp.Before(recDps[j].timestamp) })
+	idxByKind := map[int32]int{}
+	details := []ErrorDetail{}
+	droppedCnt := 0
+
+	for _, v := range recDps {
+		idx, ok := idxByKind[v.errKind]
+
+		if !ok {
+			if len(details) >= maxDetail {
+				droppedCnt++
+				continue
+			}
+
+			idx = len(details)
+			idxByKind[v.errKind] = idx
+			code, msg := rec.errorKind(v.errKind)
+			details = append(details, ErrorDetail{
+				Code:           code,
+				Name:           sqlStateNames[code],
+				Message:        msg,
+				FirstAt:        v.timestamp,
+				FirstQueryType: rec.strs.get(v.queryType),
+				FirstQueryIdx:  int(v.queryIdx),
+			})
+		}
+
+		details[idx].Count++
+	}
+
+	sort.SliceStable(details, func(i, j int) bool { return details[i].Count > details[j].Count })
+	return details, droppedCnt
+}
+
+// Number of the failed queries that timed out, e.g. by '--query-timeout'.
+func (rec *Recorder) timeoutCount() int {
+	cnt := 0
+
+	for _, v := range rec.errorDataPoints {
+		if v.timedOut {
+			cnt++
+		}
+	}
+
+	return cnt
+}
+
+// e.g. "57014 query_canceled: 42 occurrences (first at 00:03:12): canceling statement due to user request"
+func (ed *ErrorDetail) String(startedAt time.Time) string {
+	first := ed.FirstAt.Sub(startedAt).Round(time.Second)
+
+	if first < 0 {
+		first = 0
+	}
+
+	h := first / time.Hour
+	m := (first - h*time.Hour) / time.Minute
+	s := (first - h*time.Hour - m*time.Minute) / time.Second
+	sig := strings.TrimSpace(ed.Code + " " + ed.Name)
+
+	return fmt.Sprintf("%s: %d occurrences (first at %02d:%02d:%02d in %s): %s", sig, ed.Count, h, m, s, ed.FirstQueryType, ed.Message)
+}
@@ -0,0 +1,72 @@
+package rsslap
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	ErrorRateWindow      = 10 * time.Second // rolling window of '--max-error-rate'
+	ErrorRateCheckPeriod = 1 * time.Second
+)
+
+// Ratio of the failed queries to all the queries finished in the last window, and the number of the queries.
+// NOTE: The agents send the data points in batches of RecordPeriod, so the scan goes back one more period
+func (rec *Recorder) recentErrorRate(window time.Duration) (float64, int) {
+	rec.Lock()
+	defer rec.Unlock()
+	since := time.Now().Add(-window)
+	scanFrom := since.Add(-RecordPeriod)
+
+	count := func(recDps []recorderDataPoint) int {
+		cnt := 0
+
+		for i := len(recDps) - 1; i >= 0 && recDps[i].timestamp.After(scanFrom); i-- {
+			if recDps[i].timestamp.After(since) {
+				cnt++
+			}
+		}
+
+		return cnt
+	}
+
+	errCnt := count(rec.errorDataPoints)
+	total := errCnt + count(rec.dataPoints)
+
+	if total == 0 {
+		return 0, 0
+	}
+
+	return float64(errCnt) / float64(total), total
+}
+
+// Abort the test when the error rate of the last ErrorRateWindow exceeds '--max-error-rate'.
+// NOTE: The first check is after a full window from the start of the measurement
+func (task *Task) watchErrorRate(ctx context.Context, cancel context.CancelFunc, rec *Recorder) {
+	tick := time.NewTicker(ErrorRateCheckPeriod)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			if !rec.isMeasuring() || time.Since(rec.measureStart()) < ErrorRateWindow {
+				continue
+			}
+
+			errRate, total := rec.recentErrorRate(ErrorRateWindow)
+
+			if errRate > task.MaxErrorRate {
+				reason := fmt.Sprintf("error rate %.4f of %d queries in the last %s exceeded the limit (%g)",
+					errRate, total, ErrorRateWindow, task.MaxErrorRate)
+				fmt.Fprintf(os.Stderr, "\n[ERROR] Abort: %s\n", reason)
+				rec.abort(reason)
+				cancel()
+				return
+			}
+		}
+	}
+}
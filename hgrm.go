@@ -0,0 +1,78 @@
+package rsslap
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	// Same as the default of HdrHistogram's outputPercentileDistribution
+	HgrmPercentileTicksPerHalfDistance = 5
+)
+
+// Write the response times in the percentile distribution format of HdrHistogram (values in ms).
+// NOTE: The values are exact, as the recorder keeps all samples
+func writeHgrm(path string, resTimes []time.Duration) error {
+	f, err := os.Create(path)
+
+	if err != nil {
+		return fmt.Errorf("failed to create hgrm file (path=%s): %w", path, err)
+	}
+
+	w := bufio.NewWriter(f)
+	sorted := make([]time.Duration, len(resTimes))
+	copy(sorted, resTimes)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	n := len(sorted)
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+
+	fmt.Fprintf(w, "%12s %14s %10s %14s\n\n", "Value", "Percentile", "TotalCount", "1/(1-Percentile)")
+
+	for level := 0.0; n > 0 && level < 100; {
+		v := percentile(sorted, level)
+		cnt := sort.Search(n, func(i int) bool { return sorted[i] > v })
+
+		if cnt >= n {
+			break
+		}
+
+		fmt.Fprintf(w, "%12.3f %2.12f %10d %14.2f\n", ms(v), level/100, cnt, 1/(1-level/100))
+
+		// Halve the distance to 100% every HgrmPercentileTicksPerHalfDistance lines
+		ticks := HgrmPercentileTicksPerHalfDistance * math.Pow(2, math.Floor(math.Log2(100/(100-level)))+1)
+		level += 100 / ticks
+	}
+
+	mean, stdDev, max := 0.0, 0.0, 0.0
+
+	if n > 0 {
+		max = ms(sorted[n-1])
+		fmt.Fprintf(w, "%12.3f %2.12f %10d\n", max, 1.0, n)
+
+		for _, v := range sorted {
+			mean += ms(v)
+		}
+
+		mean /= float64(n)
+
+		for _, v := range sorted {
+			stdDev += math.Pow(ms(v)-mean, 2)
+		}
+
+		stdDev = math.Sqrt(stdDev / float64(n))
+	}
+
+	fmt.Fprintf(w, "#[Mean    = %12.3f, StdDeviation   = %12.3f]\n", mean, stdDev)
+	fmt.Fprintf(w, "#[Max     = %12.3f, Total count    = %12d]\n", max, n)
+
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write hgrm file (path=%s): %w", path, err)
+	}
+
+	return f.Close()
+}
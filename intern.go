@@ -0,0 +1,46 @@
+package rsslap
+
+import "sync"
+
+// Table of the distinct strings of the data points, e.g. the queries and the tags,
+// so that each data point stores the small index of the string instead of a copy.
+// NOTE: Safe for the concurrent agents, which mostly look up the strings already added
+type stringTable struct {
+	sync.RWMutex
+	idxByStr map[string]int32
+	strs     []string
+}
+
+func newStringTable() *stringTable {
+	return &stringTable{idxByStr: map[string]int32{}}
+}
+
+// Return the index of the string, adding it to the table if it is new.
+func (st *stringTable) index(s string) int32 {
+	st.RLock()
+	idx, ok := st.idxByStr[s]
+	st.RUnlock()
+
+	if ok {
+		return idx
+	}
+
+	st.Lock()
+	defer st.Unlock()
+
+	if idx, ok := st.idxByStr[s]; ok {
+		return idx
+	}
+
+	idx = int32(len(st.strs))
+	st.strs = append(st.strs, s)
+	st.idxByStr[s] = idx
+
+	return idx
+}
+
+func (st *stringTable) get(idx int32) string {
+	st.RLock()
+	defer st.RUnlock()
+	return st.strs[idx]
+}
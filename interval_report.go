@@ -0,0 +1,170 @@
+package rsslap
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// Reports the throughput and latency of each interval from the test start, once the interval is complete.
+// NOTE: Only the samples of the pending intervals are kept
+type intervalReport struct {
+	interval time.Duration
+	w        io.Writer
+	file     *os.File
+	windows  map[int]*intervalWindow
+	nextIdx  int
+}
+
+type intervalWindow struct {
+	resTimes   []time.Duration
+	sum        time.Duration
+	errCnt     int
+	timeoutCnt int
+}
+
+func openIntervalReport(interval time.Duration, path string) (*intervalReport, error) {
+	ir := &intervalReport{
+		interval: interval,
+		w:        os.Stderr,
+		windows:  map[int]*intervalWindow{},
+	}
+
+	if path != "" {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to open interval report file (path=%s): %w", path, err)
+		}
+
+		ir.w = f
+		ir.file = f
+	}
+
+	return ir, nil
+}
+
+func (ir *intervalReport) add(recDps []recorderDataPoint, startedAt time.Time) {
+	for _, v := range recDps {
+		idx := int(v.timestamp.Sub(startedAt) / ir.interval)
+
+		if idx < ir.nextIdx {
+			// NOTE: Samples of the intervals already reported are dropped
+			continue
+		}
+
+		iw, ok := ir.windows[idx]
+
+		if !ok {
+			iw = &intervalWindow{}
+			ir.windows[idx] = iw
+		}
+
+		if v.failed {
+			iw.errCnt++
+
+			if v.timedOut {
+				iw.timeoutCnt++
+			}
+		} else {
+			iw.resTimes = append(iw.resTimes, v.resTime)
+			iw.sum += v.resTime
+		}
+	}
+}
+
+// Report the intervals before 'until'. The last one ends at 'end' if it is partial.
+func (ir *intervalReport) flush(until int, end time.Duration) error {
+	for ; ir.nextIdx < until; ir.nextIdx++ {
+		from := time.Duration(ir.nextIdx) * ir.interval
+		to := from + ir.interval
+
+		if end > from && end < to {
+			to = end
+		}
+
+		iw, ok := ir.windows[ir.nextIdx]
+
+		if !ok {
+			iw = &intervalWindow{}
+		}
+
+		sort.Slice(iw.resTimes, func(i, j int) bool { return iw.resTimes[i] < iw.resTimes[j] })
+		cnt := len(iw.resTimes)
+		avg := time.Duration(0)
+
+		if cnt > 0 {
+			avg = iw.sum / time.Duration(cnt)
+		}
+
+		line := fmt.Sprintf("[INTERVAL] elapsed=%s queries=%d qps=%.1f avg=%s p99=%s errors=%d",
+			to.Round(time.Millisecond), cnt, float64(cnt)/(to-from).Seconds(), avg, percentile(iw.resTimes, 99), iw.errCnt)
+
+		if iw.timeoutCnt > 0 {
+			line += fmt.Sprintf(" timeouts=%d", iw.timeoutCnt)
+		}
+
+		// NOTE: Start a new line after the progress line
+		if ir.file == nil {
+			line = "\n" + line
+		}
+
+		if _, err := fmt.Fprintln(ir.w, line); err != nil {
+			return err
+		}
+
+		delete(ir.windows, ir.nextIdx)
+	}
+
+	return nil
+}
+
+// Report the remaining intervals including the last partial one.
+func (ir *intervalReport) close(startedAt time.Time) error {
+	end := time.Since(startedAt)
+	until := int(end / ir.interval)
+
+	if end%ir.interval > 0 {
+		until++
+	}
+
+	for idx := range ir.windows {
+		if idx >= until {
+			until = idx + 1
+		}
+	}
+
+	err := ir.flush(until, end)
+
+	if ir.file == nil {
+		return err
+	}
+
+	if err != nil {
+		ir.file.Close()
+		return err
+	}
+
+	return ir.file.Close()
+}
+
+func (rec *Recorder) writeIntervalReport(recDps []recorderDataPoint) {
+	if rec.intervalReport == nil {
+		return
+	}
+
+	rec.intervalReport.add(recDps, rec.startedAt)
+	until := int(time.Since(rec.startedAt.Add(TimeSeriesGracePeriod)) / rec.IntervalReport)
+
+	if err := rec.intervalReport.flush(until, 0); err != nil {
+		fmt.Fprintf(os.Stderr, "\n[WARN] Stop writing interval report: %s\n", err)
+
+		if rec.intervalReport.file != nil {
+			rec.intervalReport.file.Close()
+		}
+
+		rec.intervalReport = nil
+	}
+}
@@ -0,0 +1,176 @@
+package rsslap
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/winebarrel/randstr"
+)
+
+const (
+	JoinTableName  = "t2"
+	JoinKeyColName = "t1_id"
+	JoinRowsPerKey = 3 // rows of the join table referencing each pre-populated row
+)
+
+// Type of the primary key of the auto-generated table, and of the join key referencing it.
+func (data *Data) pkColType() string {
+	if data.GuidPrimary {
+		return "uuid"
+	} else if data.PkType == PkTypeNumeric {
+		return "numeric(20,4)"
+	} else if data.PkType == PkTypeVarchar {
+		return "varchar(64)"
+	}
+
+	return "bigint"
+}
+
+// Create the join table of 'join' load type, whose rows reference the rows of the auto-generated table.
+// NOTE: The join key is also the DISTKEY if the auto-generated table is distributed by the primary key,
+// so that the join is collocated
+func (data *Data) buildCreateJoinTableStmt() string {
+	sb := strings.Builder{}
+	sb.WriteString("CREATE TABLE " + JoinTableName + " (id bigint generated by default as identity(1,1) PRIMARY KEY")
+	fmt.Fprintf(&sb, ",%s %s REFERENCES %s(id)", JoinKeyColName, data.pkColType(), AutoGenerateTableName)
+
+	for i := 1; i <= data.NumberJoinIntCols; i++ {
+		fmt.Fprintf(&sb, ",intcol%d int", i)
+	}
+
+	for i := 1; i <= data.NumberJoinCharCols; i++ {
+		fmt.Fprintf(&sb, ",charcol%d varchar(%d)", i, data.CharColLength)
+	}
+
+	sb.WriteString(")")
+
+	if data.Distkey == "id" {
+		fmt.Fprintf(&sb, " DISTKEY(%s)", JoinKeyColName)
+	}
+
+	return sb.String()
+}
+
+func (data *Data) buildJoinInsertStmt(key string) (string, []interface{}) {
+	args := []interface{}{key}
+	sb := strings.Builder{}
+	sb.WriteString("INSERT INTO " + JoinTableName + " (" + JoinKeyColName)
+
+	for i := 1; i <= data.NumberJoinIntCols; i++ {
+		fmt.Fprintf(&sb, ",intcol%d", i)
+	}
+
+	for i := 1; i <= data.NumberJoinCharCols; i++ {
+		fmt.Fprintf(&sb, ",charcol%d", i)
+	}
+
+	sb.WriteString(") VALUES ($1")
+	phIdx := 2
+
+	for i := 1; i <= data.NumberJoinIntCols; i++ {
+		fmt.Fprintf(&sb, ",$%d", phIdx)
+		phIdx++
+		args = append(args, data.randSrc.Int63()>>32)
+	}
+
+	for i := 1; i <= data.NumberJoinCharCols; i++ {
+		fmt.Fprintf(&sb, ",$%d", phIdx)
+		phIdx++
+		args = append(args, randstr.String(data.randSrc, data.CharColLength))
+	}
+
+	sb.WriteString(")")
+
+	return sb.String(), args
+}
+
+// Join the tables on the join key, for a row of the auto-generated table,
+// or for a random date range of the first DATE column if any.
+func (data *Data) buildJoinStmt() (string, []interface{}) {
+	args := []interface{}{}
+	sb := strings.Builder{}
+	sb.WriteString("SELECT " + AutoGenerateTableName + ".id," + JoinTableName + ".id")
+
+	for i := 1; i <= data.NumberIntCols; i++ {
+		fmt.Fprintf(&sb, ",%s.intcol%d", AutoGenerateTableName, i)
+	}
+
+	for i := 1; i <= data.NumberJoinIntCols; i++ {
+		fmt.Fprintf(&sb, ",%s.intcol%d", JoinTableName, i)
+	}
+
+	for i := 1; i <= data.NumberJoinCharCols; i++ {
+		fmt.Fprintf(&sb, ",%s.charcol%d", JoinTableName, i)
+	}
+
+	fmt.Fprintf(&sb, " FROM %s JOIN %s ON %s.%s = %s.id", AutoGenerateTableName, JoinTableName, JoinTableName, JoinKeyColName, AutoGenerateTableName)
+
+	if data.NumberDateCols > 0 {
+		from, to := data.dateRangePredicate()
+		fmt.Fprintf(&sb, " WHERE %s.datecol1 BETWEEN $1 AND $2", AutoGenerateTableName)
+		args = append(args, from, to)
+	} else {
+		fmt.Fprintf(&sb, " WHERE %s.id = $1", AutoGenerateTableName)
+		args = append(args, data.nextId())
+	}
+
+	return sb.String(), args
+}
+
+// Whether 'join' load type is used by the test or by any phase of '--schedule'.
+func (task *Task) usesJoinTable() bool {
+	if !task.AutoGenerateSql || len(task.Creates) > 0 {
+		return false
+	} else if task.dataOpts.LoadType == LoadTypeJoin {
+		return true
+	}
+
+	for _, phase := range task.Schedule {
+		if phase.LoadType == LoadTypeJoin {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (task *Task) createJoinTable(conn DB, data *Data) error {
+	stmt := data.buildCreateJoinTableStmt()
+	_, err := conn.Exec(context.Background(), stmt)
+
+	if err != nil {
+		return fmt.Errorf("create join table error (query=%s): %w", stmt, err)
+	}
+
+	return nil
+}
+
+// Insert JoinRowsPerKey rows into the join table for each pre-populated row.
+func (task *Task) populateJoinTable(ctx context.Context, cfg *RsConfig, idList []string) error {
+	conn, err := cfg.openAndPing(ctx)
+
+	if err != nil {
+		return fmt.Errorf("connection error: %w", err)
+	}
+
+	defer cfg.closeConn(conn)
+	data := newData(task.dataOpts, nil, task.dataOpts.Seed)
+
+	for _, id := range idList {
+		for i := 0; i < JoinRowsPerKey; i++ {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			stmt, args := data.buildJoinInsertStmt(id)
+			_, err = conn.Exec(ctx, stmt, args...)
+
+			if err != nil {
+				return fmt.Errorf("insert error (query=%s, args=%v): %w", stmt, args, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package rsslap
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var latencyCSVHeader = []string{"agent_id", "query_index", "statement_type", "start_time", "duration_us", "error", "tag"}
+
+// Writes the latency of each query to a CSV file, flushing once per batch of data points.
+type latencyCSV struct {
+	file *os.File
+	w    *csv.Writer
+	row  []string
+	// Statement type of each query of Recorder.strs
+	stmtTypes map[int32]string
+}
+
+func openLatencyCSV(path string) (*latencyCSV, error) {
+	f, err := os.Create(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create latency CSV (path=%s): %w", path, err)
+	}
+
+	lc := &latencyCSV{
+		file:      f,
+		w:         csv.NewWriter(f),
+		row:       make([]string, len(latencyCSVHeader)),
+		stmtTypes: map[int32]string{},
+	}
+
+	if err := lc.w.Write(latencyCSVHeader); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write latency CSV (path=%s): %w", path, err)
+	}
+
+	return lc, nil
+}
+
+func (lc *latencyCSV) write(recDps []recorderDataPoint, strs *stringTable) error {
+	for _, v := range recDps {
+		stmtType, ok := lc.stmtTypes[v.query]
+
+		if !ok {
+			stmtType = statementType(strs.get(v.query))
+			lc.stmtTypes[v.query] = stmtType
+		}
+
+		lc.row[0] = strconv.Itoa(int(v.agentId))
+		lc.row[1] = strconv.Itoa(int(v.queryIdx))
+		lc.row[2] = stmtType
+		lc.row[3] = v.timestamp.Add(-v.resTime).Format(time.RFC3339Nano)
+		lc.row[4] = strconv.FormatInt(v.resTime.Microseconds(), 10)
+		lc.row[5] = strconv.FormatBool(v.failed)
+		lc.row[6] = strs.get(v.queryTag)
+
+		if err := lc.w.Write(lc.row); err != nil {
+			return err
+		}
+	}
+
+	lc.w.Flush()
+	return lc.w.Error()
+}
+
+func (lc *latencyCSV) close() error {
+	lc.w.Flush()
+
+	if err := lc.w.Error(); err != nil {
+		lc.file.Close()
+		return err
+	}
+
+	return lc.file.Close()
+}
+
+func (rec *Recorder) writeLatencyCSV(recDps []recorderDataPoint) {
+	if rec.latencyCSV == nil {
+		return
+	}
+
+	if err := rec.latencyCSV.write(recDps, rec.strs); err != nil {
+		fmt.Fprintf(os.Stderr, "\n[WARN] Stop writing latency CSV: %s\n", err)
+		rec.latencyCSV.file.Close()
+		rec.latencyCSV = nil
+	}
+}
+
+// Return the first keyword of the statement in lower case, e.g. "select".
+func statementType(q string) string {
+	q = strings.TrimLeft(q, " \t\r\n(")
+	end := strings.IndexFunc(q, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	})
+
+	if end >= 0 {
+		q = q[:end]
+	}
+
+	return strings.ToLower(q)
+}
@@ -0,0 +1,106 @@
+package rsslap
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Renders the histogram of the response times accumulated so far to stderr in place of the progress line.
+type liveHistogram struct {
+	sorted  []time.Duration
+	readIdx int
+	lineCnt int
+	tty     bool
+}
+
+// NOTE: If stdout is not a terminal, a summary line is printed on each update instead of the histogram
+func newLiveHistogram() *liveHistogram {
+	return &liveHistogram{tty: term.IsTerminal(int(os.Stdout.Fd()))}
+}
+
+func (lh *liveHistogram) render(task *Task, rec *Recorder, execCnt int, prevExecCnt int, taskStart time.Time, numTermAgents int) {
+	var resTimes []time.Duration
+	resTimes, lh.readIdx = rec.resTimesSince(lh.readIdx)
+	lh.sorted = mergeSorted(lh.sorted, resTimes)
+	qps := float64(execCnt-prevExecCnt) / ProgressReportPeriod
+	elapsed := time.Since(taskStart)
+	header := fmt.Sprintf("%s | %d agents / run %d queries (%.0f qps)", elapsed.Round(time.Second), task.NAgents-numTermAgents, execCnt, qps)
+
+	if phase := task.phase(rec, elapsed); phase != "" {
+		header = fmt.Sprintf("%s | %s | %d agents / run %d queries (%.0f qps)", elapsed.Round(time.Second), phase, task.NAgents-numTermAgents, execCnt, qps)
+	}
+
+	if !lh.tty {
+		fmt.Fprintf(os.Stderr, "%s | p50=%s p99=%s\n", header, percentile(lh.sorted, 50), percentile(lh.sorted, 99))
+		return
+	}
+
+	hist := strings.Builder{}
+	writeHistogramText(&hist, histogram(lh.sorted, rec.HInterval))
+	lines := []string{header}
+
+	if hist.Len() > 0 {
+		lines = append(lines, strings.Split(strings.TrimSuffix(hist.String(), "\n"), "\n")...)
+	}
+
+	// NOTE: Clear the rest of the previous rendering if the number of the buckets decreased
+	for len(lines) < lh.lineCnt {
+		lines = append(lines, "")
+	}
+
+	sb := strings.Builder{}
+
+	// Move the cursor back to the top of the previous rendering
+	if lh.lineCnt > 0 {
+		fmt.Fprintf(&sb, "\r\033[%dA", lh.lineCnt)
+	}
+
+	for _, line := range lines {
+		sb.WriteString("\r\033[K" + line + "\n")
+	}
+
+	lh.lineCnt = len(lines)
+	fmt.Fprint(os.Stderr, sb.String())
+}
+
+// Return the response times recorded from the index, and the index to read next.
+func (rec *Recorder) resTimesSince(idx int) ([]time.Duration, int) {
+	rec.Lock()
+	defer rec.Unlock()
+	resTimes := make([]time.Duration, 0, len(rec.dataPoints)-idx)
+
+	for _, v := range rec.dataPoints[idx:] {
+		resTimes = append(resTimes, v.resTime)
+	}
+
+	return resTimes, len(rec.dataPoints)
+}
+
+// Merge the new values into the sorted ones, not to sort all the values on each update.
+func mergeSorted(sorted []time.Duration, values []time.Duration) []time.Duration {
+	if len(values) == 0 {
+		return sorted
+	}
+
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	merged := make([]time.Duration, 0, len(sorted)+len(values))
+	i, j := 0, 0
+
+	for i < len(sorted) && j < len(values) {
+		if sorted[i] <= values[j] {
+			merged = append(merged, sorted[i])
+			i++
+		} else {
+			merged = append(merged, values[j])
+			j++
+		}
+	}
+
+	merged = append(merged, sorted[i:]...)
+	return append(merged, values[j:]...)
+}
@@ -0,0 +1,518 @@
+package rsslap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type OutputFormat string
+
+const (
+	OutputFormatJSON     = OutputFormat("json")
+	OutputFormatText     = OutputFormat("text")
+	OutputFormatMarkdown = OutputFormat("markdown")
+	HistogramBarWidth    = 40
+	ReportVersion        = 1 // incremented when a field of the JSON report is renamed or removed
+)
+
+// Writes the report in an output format.
+type Formatter interface {
+	Format(w io.Writer, rr *RecorderReport) error
+}
+
+type JSONFormatter struct{}
+type TextFormatter struct{}
+type MarkdownFormatter struct{}
+
+// Return the formatter of the output format, or nil if the format is unknown.
+func (format OutputFormat) Formatter() Formatter {
+	switch format {
+	case OutputFormatJSON:
+		return &JSONFormatter{}
+	case OutputFormatText:
+		return &TextFormatter{}
+	case OutputFormatMarkdown:
+		return &MarkdownFormatter{}
+	default:
+		return nil
+	}
+}
+
+// Write the report with the formatter.
+func (rr *RecorderReport) Write(w io.Writer, formatter Formatter) error {
+	return formatter.Format(w, rr)
+}
+
+func (*JSONFormatter) Format(w io.Writer, rr *RecorderReport) error {
+	rawJson, err := json.MarshalIndent(rr, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w, string(rawJson))
+	return err
+}
+
+func (*TextFormatter) Format(w io.Writer, rr *RecorderReport) error {
+	sb := strings.Builder{}
+	fmt.Fprintf(&sb, "URL:             %s\n", rr.URL)
+
+	if rr.CommandLine != "" {
+		fmt.Fprintf(&sb, "Command line:    %s\n", rr.CommandLine)
+	}
+
+	if rr.ConfigHash != "" {
+		fmt.Fprintf(&sb, "Config hash:     %s\n", rr.ConfigHash)
+	}
+
+	fmt.Fprintf(&sb, "Started at:      %s\n", rr.StartedAt.Format(time.RFC3339))
+	fmt.Fprintf(&sb, "Finished at:     %s\n", rr.FinishedAt.Format(time.RFC3339))
+	fmt.Fprintf(&sb, "Elapsed time:    %ds\n", rr.ElapsedTime)
+	fmt.Fprintf(&sb, "Agents:          %d\n", rr.NAgents)
+
+	if rr.DeferredAgents > 0 {
+		fmt.Fprintf(&sb, "Deferred agents: %d\n", rr.DeferredAgents)
+	}
+
+	if len(rr.QuerySources) > 0 {
+		fmt.Fprintf(&sb, "Query sources:   %s\n", querySourcesString(rr.QuerySources))
+	}
+
+	fmt.Fprintf(&sb, "Queries:         %d\n", rr.QueryCount)
+
+	if rr.ErrorCount > 0 {
+		fmt.Fprintf(&sb, "Errors:          %d\n", rr.ErrorCount)
+
+		for _, ed := range rr.Errors {
+			fmt.Fprintf(&sb, "  %s\n", ed.String(rr.StartedAt))
+		}
+
+		if rr.DroppedErrorDetailCount > 0 {
+			fmt.Fprintf(&sb, "  (%d errors of other kinds)\n", rr.DroppedErrorDetailCount)
+		}
+	}
+
+	if rr.TimeoutCount > 0 {
+		fmt.Fprintf(&sb, "Timeouts:        %d\n", rr.TimeoutCount)
+	}
+
+	if rr.RetryCount > 0 {
+		fmt.Fprintf(&sb, "Retries:         %d\n", rr.RetryCount)
+	}
+
+	if rr.AbandonedCount > 0 {
+		fmt.Fprintf(&sb, "Abandoned:       %d\n", rr.AbandonedCount)
+	}
+
+	if rr.ResultMemLimitExceededCount > 0 {
+		fmt.Fprintf(&sb, "Mem limit hits:  %d (results over '--agent-result-mem-limit')\n", rr.ResultMemLimitExceededCount)
+	}
+
+	if rr.RampUp > 0 {
+		included := "excluded"
+
+		if rr.IncludeRampUp {
+			included = "included"
+		}
+
+		fmt.Fprintf(&sb, "Ramp-up:         %s (%s)\n", rr.RampUp, included)
+	}
+
+	if rr.Warmup > 0 {
+		fmt.Fprintf(&sb, "Warm-up:         %s (excluded)\n", rr.Warmup)
+	} else if rr.WarmupQueries > 0 {
+		fmt.Fprintf(&sb, "Warm-up:         %d queries per agent (excluded)\n", rr.WarmupQueries)
+	}
+
+	if rr.UnmeasuredQueryCount > 0 {
+		fmt.Fprintf(&sb, "Unmeasured:      %d queries\n", rr.UnmeasuredQueryCount)
+	}
+
+	if rr.Drain {
+		fmt.Fprintf(&sb, "Drain:           completed=%d abandoned=%d (timeout=%s)\n", rr.DrainedQueryCount, rr.DrainAbandonedCount, rr.DrainTimeout)
+	}
+
+	fmt.Fprintf(&sb, "QPS:             avg=%.1f min=%.1f max=%.1f median=%.1f\n", rr.AvgQPS, rr.MinQPS, rr.MaxQPS, rr.MedianQPS)
+
+	if rr.GlobalRate > 0 {
+		fmt.Fprintf(&sb, "Global rate:     requested=%.1f achieved=%.1f\n", rr.GlobalRate, rr.AvgQPS)
+	}
+
+	if rr.Rate > 0 || rr.GlobalRate > 0 {
+		fmt.Fprintf(&sb, "Arrival:         %s\n", rr.Arrival)
+	}
+
+	if rr.AbortReason != "" {
+		fmt.Fprintf(&sb, "Abort reason:    %s\n", rr.AbortReason)
+	}
+
+	if rr.SLA != nil {
+		fmt.Fprintf(&sb, "SLA:             %s\n", rr.SLA)
+	}
+
+	if rr.ConnectTime != nil {
+		fmt.Fprintf(&sb, "Connect time:    %s\n", rr.ConnectTime)
+	}
+
+	if rr.ResultChecksum != "" {
+		fmt.Fprintf(&sb, "Result checksum: %s (rows=%d)\n", rr.ResultChecksum, rr.ResultRows)
+	}
+
+	if rr.BytesWritten > 0 {
+		fmt.Fprintf(&sb, "Bytes written:   %d (%.0f bytes/s)\n", rr.BytesWritten, rr.BytesWrittenPerSec)
+	}
+
+	if len(rr.Steps) > 0 {
+		sb.WriteString("\nSteps:\n")
+		fmt.Fprintf(&sb, "  %4s %6s %10s %8s %10s %12s %12s %12s\n", "step", "agents", "queries", "errors", "qps", "avg", "p50", "p99")
+
+		for _, st := range rr.Steps {
+			fmt.Fprintf(&sb, "  %4d %6d %10d %8d %10.1f %12s %12s %12s\n", st.Step, st.Agents, st.QueryCount, st.ErrorCount, st.QPS, st.Avg, st.P50, st.P99)
+		}
+	}
+
+	if len(rr.Phases) > 0 {
+		sb.WriteString("\nPhases:\n")
+		fmt.Fprintf(&sb, "  %5s %-16s %6s %10s %8s %10s %12s %12s %12s\n", "phase", "name", "agents", "queries", "errors", "qps", "avg", "p50", "p99")
+
+		for _, ph := range rr.Phases {
+			fmt.Fprintf(&sb, "  %5d %-16s %6d %10d %8d %10.1f %12s %12s %12s\n", ph.Phase, ph.Name, ph.Agents, ph.QueryCount, ph.ErrorCount, ph.QPS, ph.Avg, ph.P50, ph.P99)
+		}
+	}
+
+	if len(rr.ColdWarm) > 0 {
+		sb.WriteString("\nCold / warm:\n")
+		fmt.Fprintf(&sb, "  %12s %8s %12s %12s %12s  %s\n", "cold", "warm", "warm avg", "warm p50", "warm p99", "query")
+
+		for _, cw := range rr.ColdWarm {
+			fmt.Fprintf(&sb, "  %12s %8d %12s %12s %12s  %s\n", cw.ColdTime, cw.WarmCount, cw.WarmAvg, cw.WarmP50, cw.WarmP99, cw.Query)
+		}
+	}
+
+	writeResponseText(&sb, "Response", rr.Response)
+
+	if rr.ErrorResponse != nil {
+		writeResponseText(&sb, "Error response", rr.ErrorResponse)
+	}
+
+	for _, qt := range rr.QueryTypes {
+		title := fmt.Sprintf("Response of %s (%s)", qt.Type, qt.summary())
+		writeResponseText(&sb, title, qt.Response)
+	}
+
+	for _, st := range rr.StatementTypes {
+		title := fmt.Sprintf("Response of %s statements (count=%d errors=%d qps=%.1f)", strings.ToUpper(st.Type), st.Count, st.ErrorCount, st.QPS)
+		writeResponseText(&sb, title, st.Response)
+	}
+
+	for _, tag := range rr.Tags {
+		title := fmt.Sprintf("Response of tag %s (%s)", tag.Type, tag.summary())
+		writeResponseText(&sb, title, tag.Response)
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// Write the run parameters, the results table and the histograms, e.g. to attach to a pull request.
+func (*MarkdownFormatter) Format(w io.Writer, rr *RecorderReport) error {
+	sb := strings.Builder{}
+	host := "-"
+
+	if rr.RsConfig != nil && rr.RsConfig.ConnConfig != nil {
+		host = fmt.Sprintf("%s:%d", rr.RsConfig.Host, rr.RsConfig.Port)
+	}
+
+	loadType := "custom queries"
+
+	if rr.AutoGenerateSql {
+		loadType = string(rr.LoadType)
+	}
+
+	sb.WriteString("# rsslap report\n\n")
+	sb.WriteString("## Parameters\n\n")
+	sb.WriteString("| Parameter | Value |\n")
+	sb.WriteString("| --- | --- |\n")
+	param := func(name string, value interface{}) {
+		fmt.Fprintf(&sb, "| %s | %s |\n", name, markdownEscape(fmt.Sprint(value)))
+	}
+
+	param("Cluster host", host)
+
+	if rr.RsConfig != nil && rr.RsConfig.IsolationLevel != "" {
+		param("Isolation level", rr.RsConfig.IsolationLevel)
+	}
+
+	param("Agents", rr.NAgents)
+
+	if rr.DBPerAgent {
+		param("Database per agent", true)
+	}
+
+	if rr.Time > 0 {
+		param("Time", rr.Time)
+	} else {
+		param("Time", "infinity")
+	}
+
+	if rr.RampUp > 0 {
+		param("Ramp-up", rr.RampUp)
+	}
+
+	if rr.Warmup > 0 {
+		param("Warm-up", rr.Warmup)
+	} else if rr.WarmupQueries > 0 {
+		param("Warm-up", fmt.Sprintf("%d queries per agent", rr.WarmupQueries))
+	}
+
+	if rr.Rate > 0 {
+		param("Rate", rr.Rate)
+	}
+
+	if rr.GlobalRate > 0 {
+		param("Global rate", rr.GlobalRate)
+	}
+
+	if rr.Rate > 0 || rr.GlobalRate > 0 {
+		param("Arrival", rr.Arrival)
+	}
+
+	if rr.NumberQueriesToExecute > 0 {
+		param("Number of queries", rr.NumberQueriesToExecute)
+	}
+
+	param("Seed", rr.Seed)
+
+	param("Load type", loadType)
+
+	if rr.AutoGenerateSql {
+		param("Primary key", rr.PkType)
+		param("Int columns", rr.NumberIntCols)
+		param("Decimal columns", fmt.Sprintf("%d (numeric(%s))", rr.NumberDecimalCols, rr.DecimalPrecision))
+		param("Timestamp columns", rr.NumberTimestampCols)
+
+		if rr.NumberDateCols > 0 {
+			param("Date columns", fmt.Sprintf("%d (%s - %s)", rr.NumberDateCols, rr.DateRangeStart.Format(DateColLayout), rr.DateRangeEnd.Format(DateColLayout)))
+		}
+
+		if rr.NumberBoolCols > 0 {
+			param("Boolean columns", rr.NumberBoolCols)
+		}
+
+		param("Char columns", fmt.Sprintf("%d (length=%d)", rr.NumberCharCols, rr.CharColLength))
+
+		if rr.NullProbability > 0 {
+			param("NULL probability", rr.NullProbability)
+		}
+
+		param("Secondary indexes", rr.NumberSecondaryIndexes)
+
+		if rr.LoadType == LoadTypeJoin {
+			param("Join table columns", fmt.Sprintf("%d int, %d char", rr.NumberJoinIntCols, rr.NumberJoinCharCols))
+		}
+
+		if rr.DistStyle != "" {
+			param("DISTSTYLE", rr.DistStyle)
+		}
+
+		if rr.Distkey != "" {
+			param("DISTKEY", rr.Distkey)
+		}
+
+		if len(rr.Sortkey) > 0 {
+			param("SORTKEY", strings.Join(rr.Sortkey, ","))
+		}
+
+		param("Pre-populated rows", rr.NumberPrePopulatedData)
+	} else {
+		param("Query distribution", rr.QueryDistribution)
+
+		if len(rr.QuerySources) > 0 {
+			param("Query sources", querySourcesString(rr.QuerySources))
+		}
+	}
+
+	param("Started at", rr.StartedAt.Format(time.RFC3339))
+	param("Finished at", rr.FinishedAt.Format(time.RFC3339))
+
+	if rr.CommandLine != "" {
+		param("Command line", "`"+rr.CommandLine+"`")
+	}
+
+	if rr.ConfigHash != "" {
+		param("Config hash", rr.ConfigHash)
+	}
+
+	sb.WriteString("\n## Results\n\n")
+	sb.WriteString("| Metric | Value |\n")
+	sb.WriteString("| --- | --- |\n")
+	param("Elapsed time", fmt.Sprintf("%ds", rr.ElapsedTime))
+	param("Queries", rr.QueryCount)
+	param("Errors", rr.ErrorCount)
+
+	if rr.TimeoutCount > 0 {
+		param("Timeouts", rr.TimeoutCount)
+	}
+
+	if rr.RetryCount > 0 {
+		param("Retries", rr.RetryCount)
+	}
+
+	if rr.Drain {
+		param("Drain (completed / abandoned)", fmt.Sprintf("%d / %d", rr.DrainedQueryCount, rr.DrainAbandonedCount))
+	}
+
+	param("QPS (avg)", fmt.Sprintf("%.1f", rr.AvgQPS))
+	param("QPS (median)", fmt.Sprintf("%.1f", rr.MedianQPS))
+	param("QPS (min / max)", fmt.Sprintf("%.1f / %.1f", rr.MinQPS, rr.MaxQPS))
+
+	if m := rr.Response; m != nil {
+		param("Response avg", m.Avg)
+		param("Response min", m.Min)
+
+		for _, p := range m.Percentiles {
+			param("Response p"+strconv.FormatFloat(p.Percentile, 'f', -1, 64), p.Value)
+		}
+
+		param("Response max", m.Max)
+		param("Response stddev", m.StdDev)
+	}
+
+	if rr.AbortReason != "" {
+		param("Abort reason", rr.AbortReason)
+	}
+
+	if rr.SLA != nil {
+		param("SLA", rr.SLA)
+	}
+
+	if rr.ConnectTime != nil {
+		param("Connect time (min / avg / max)", fmt.Sprintf("%s / %s / %s", rr.ConnectTime.Min, rr.ConnectTime.Avg, rr.ConnectTime.Max))
+	}
+
+	if len(rr.Steps) > 0 {
+		sb.WriteString("\n## Steps\n\n")
+		sb.WriteString("| Step | Agents | Queries | Errors | QPS | Avg | p50 | p99 |\n")
+		sb.WriteString("| ---: | ---: | ---: | ---: | ---: | ---: | ---: | ---: |\n")
+
+		for _, st := range rr.Steps {
+			fmt.Fprintf(&sb, "| %d | %d | %d | %d | %.1f | %s | %s | %s |\n", st.Step, st.Agents, st.QueryCount, st.ErrorCount, st.QPS, st.Avg, st.P50, st.P99)
+		}
+	}
+
+	if len(rr.Phases) > 0 {
+		sb.WriteString("\n## Phases\n\n")
+		sb.WriteString("| Phase | Name | Agents | Queries | Errors | QPS | Avg | p50 | p99 |\n")
+		sb.WriteString("| ---: | --- | ---: | ---: | ---: | ---: | ---: | ---: | ---: |\n")
+
+		for _, ph := range rr.Phases {
+			fmt.Fprintf(&sb, "| %d | %s | %d | %d | %d | %.1f | %s | %s | %s |\n", ph.Phase, markdownEscape(ph.Name), ph.Agents, ph.QueryCount, ph.ErrorCount, ph.QPS, ph.Avg, ph.P50, ph.P99)
+		}
+	}
+
+	if len(rr.Errors) > 0 {
+		sb.WriteString("\n## Errors\n\n")
+
+		for _, ed := range rr.Errors {
+			fmt.Fprintf(&sb, "- %s\n", markdownEscape(ed.String(rr.StartedAt)))
+		}
+	}
+
+	writeResponseMarkdown(&sb, "Response", rr.Response)
+
+	if rr.ErrorResponse != nil {
+		writeResponseMarkdown(&sb, "Error response", rr.ErrorResponse)
+	}
+
+	for _, qt := range rr.QueryTypes {
+		title := fmt.Sprintf("Response of %s (%s)", qt.Type, qt.summary())
+		writeResponseMarkdown(&sb, title, qt.Response)
+	}
+
+	for _, st := range rr.StatementTypes {
+		title := fmt.Sprintf("Response of %s statements (count=%d errors=%d qps=%.1f)", strings.ToUpper(st.Type), st.Count, st.ErrorCount, st.QPS)
+		writeResponseMarkdown(&sb, title, st.Response)
+	}
+
+	for _, tag := range rr.Tags {
+		title := fmt.Sprintf("Response of tag %s (%s)", tag.Type, tag.summary())
+		writeResponseMarkdown(&sb, title, tag.Response)
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// Write the histogram as a fenced code block with the same bars as the text format.
+func writeResponseMarkdown(sb *strings.Builder, title string, m *ResponseMetrics) {
+	if m == nil || len(m.Histogram) == 0 {
+		return
+	}
+
+	fmt.Fprintf(sb, "\n## %s histogram\n\n", title)
+	sb.WriteString("```\n")
+	writeHistogramText(sb, m.Histogram)
+	sb.WriteString("```\n")
+}
+
+// e.g. "queries/a.sql=3 queries/b.sql=12"
+func querySourcesString(sources []QuerySource) string {
+	ss := make([]string, len(sources))
+
+	for i, src := range sources {
+		ss[i] = fmt.Sprintf("%s=%d", src.Source, src.Count)
+	}
+
+	return strings.Join(ss, " ")
+}
+
+// Escape the characters that break a table cell.
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(s)
+}
+
+func writeResponseText(sb *strings.Builder, title string, m *ResponseMetrics) {
+	if m == nil {
+		return
+	}
+
+	fmt.Fprintf(sb, "\n%s:\n", title)
+	fmt.Fprintf(sb, "  avg=%s min=%s max=%s stddev=%s\n", m.Avg, m.Min, m.Max, m.StdDev)
+
+	if len(m.Percentiles) > 0 {
+		sb.WriteString(" ")
+
+		for _, p := range m.Percentiles {
+			fmt.Fprintf(sb, " p%s=%s", strconv.FormatFloat(p.Percentile, 'f', -1, 64), p.Value)
+		}
+
+		sb.WriteString("\n")
+	}
+
+	writeHistogramText(sb, m.Histogram)
+}
+
+func writeHistogramText(sb *strings.Builder, histogram []HistogramBucket) {
+	maxCnt := 0
+
+	for _, b := range histogram {
+		if b.Count > maxCnt {
+			maxCnt = b.Count
+		}
+	}
+
+	for _, b := range histogram {
+		bar := 0
+
+		if maxCnt > 0 {
+			bar = b.Count * HistogramBarWidth / maxCnt
+		}
+
+		fmt.Fprintf(sb, "  %12s - %-12s %-*s %d\n", b.From, b.To, HistogramBarWidth, strings.Repeat("*", bar), b.Count)
+	}
+}
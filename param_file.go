@@ -0,0 +1,92 @@
+package rsslap
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+)
+
+type ParamOrder string
+
+const (
+	ParamOrderSequential = ParamOrder("sequential")
+	ParamOrderRandom     = ParamOrder("random")
+)
+
+// Rows of '--param-file' whose values are referenced by '{{col NAME}}' of '--sql-template'.
+// NOTE: All rows are loaded into memory once and shared by the agents
+type ParamTable struct {
+	Columns []string
+	Rows    [][]string
+	colIdx  map[string]int
+}
+
+// Load the CSV file whose first line is the header of the column names.
+func LoadParamFile(path string) (*ParamTable, error) {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not open the param file: %w", err)
+	}
+
+	defer f.Close()
+	r := csv.NewReader(f)
+	header, err := r.Read()
+
+	if err == io.EOF {
+		return nil, fmt.Errorf("no header in the param file (path=%s)", path)
+	} else if err != nil {
+		return nil, fmt.Errorf("invalid param file (path=%s): %w", path, err)
+	}
+
+	pt := &ParamTable{
+		Columns: header,
+		colIdx:  map[string]int{},
+	}
+
+	for i, col := range header {
+		if _, ok := pt.colIdx[col]; ok {
+			return nil, fmt.Errorf("duplicate column in the param file (path=%s): %s", path, col)
+		}
+
+		pt.colIdx[col] = i
+	}
+
+	// NOTE: The reader rejects the rows with a different number of fields from the header
+	pt.Rows, err = r.ReadAll()
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid param file (path=%s): %w", path, err)
+	} else if len(pt.Rows) == 0 {
+		return nil, fmt.Errorf("no rows in the param file (path=%s)", path)
+	}
+
+	return pt, nil
+}
+
+// Start the agent at its own offset of the rows, so that the agents do not read the same rows in lockstep.
+func (data *Data) shardParams(agentId int, nAgents int) {
+	if data.Params == nil || nAgents < 1 {
+		return
+	}
+
+	data.paramIdx = agentId * len(data.Params.Rows) / nAgents
+}
+
+// Value of the column of the row for the current query, which is picked by the first '{{col NAME}}' of it.
+func (data *Data) paramValue(col string) string {
+	if data.paramRow == nil {
+		rows := data.Params.Rows
+
+		if data.ParamOrder == ParamOrderRandom {
+			data.paramRow = rows[data.randSrc.Int63()%int64(len(rows))]
+		} else {
+			// NOTE: Wrap around at the end of the rows
+			data.paramRow = rows[data.paramIdx]
+			data.paramIdx = (data.paramIdx + 1) % len(rows)
+		}
+	}
+
+	return data.paramRow[data.Params.colIdx[col]]
+}
@@ -0,0 +1,118 @@
+package rsslap
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Connections shared by the agents with '--pool-size'.
+// NOTE: Each agent holds a connection only while a query is running
+type connPool struct {
+	*pgxpool.Pool
+	// for '--only-print', which does not connect
+	nullDB DB
+}
+
+func newConnPool(ctx context.Context, rsConfig *RsConfig, size int, preQueries []string, continueOnPreQueryError bool) (*connPool, error) {
+	if rsConfig.OnlyPrint {
+		nullDB := &NullDB{}
+		err := execPreQueries(nullDB, preQueries, continueOnPreQueryError, "pool conn")
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &connPool{nullDB: nullDB}, nil
+	}
+
+	poolConfig, err := pgxpool.ParseConfig(rsConfig.ConnString())
+
+	if err != nil {
+		return nil, err
+	}
+
+	poolConfig.ConnConfig = rsConfig.ConnConfig.Copy()
+	poolConfig.MaxConns = int32(size)
+	poolConfig.MinConns = int32(size)
+	poolConfig.LazyConnect = true
+
+	// NOTE: Also for the connections that replace the ones closed by the queries
+	poolConfig.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		err := rsConfig.setIsolationLevel(ctx, conn)
+
+		if err != nil {
+			return err
+		}
+
+		return execPreQueries(conn, preQueries, continueOnPreQueryError, "pool conn")
+	}
+
+	p, err := pgxpool.ConnectConfig(ctx, poolConfig)
+
+	if err != nil {
+		return nil, err
+	}
+
+	pool := &connPool{Pool: p}
+
+	// Open all connections before the test
+	conns := make([]*pgxpool.Conn, 0, size)
+	defer func() {
+		for _, conn := range conns {
+			conn.Release()
+		}
+	}()
+
+	for i := 0; i < size; i++ {
+		conn, err := p.Acquire(ctx)
+
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("failed to open/ping DB (pool conn=%d, dsn=%s): %w", i, rsConfig.ConnString(), err)
+		}
+
+		conns = append(conns, conn)
+	}
+
+	return pool, nil
+}
+
+// Take a connection for a query, with the handle to release it, which is nil for '--only-print'.
+func (pool *connPool) acquire(ctx context.Context) (DB, *pgxpool.Conn, error) {
+	if pool.Pool == nil {
+		return pool.nullDB, nil, ctx.Err()
+	}
+
+	conn, err := pool.Acquire(ctx)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return conn.Conn(), conn, nil
+}
+
+// NOTE: pgxpool destroys the connection closed by a canceled query, and opens a new one in its place
+func (pool *connPool) release(conn *pgxpool.Conn) {
+	if conn != nil {
+		conn.Release()
+	}
+}
+
+// Number of the open connections, including the ones being opened.
+func (pool *connPool) connCount() int {
+	if pool.Pool == nil {
+		return 0
+	}
+
+	return int(pool.Stat().TotalConns())
+}
+
+func (pool *connPool) close() {
+	if pool.Pool != nil {
+		pool.Close()
+	}
+}
@@ -0,0 +1,69 @@
+package rsslap
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// Profiles rsslap itself during the measured phase, writing standard pprof files.
+type profiler struct {
+	cpuFile *os.File
+	memPath string
+}
+
+func startProfiler(cpuPath string, memPath string) (*profiler, error) {
+	prof := &profiler{memPath: memPath}
+
+	if cpuPath != "" {
+		f, err := os.Create(cpuPath)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to create CPU profile (path=%s): %w", cpuPath, err)
+		}
+
+		err = pprof.StartCPUProfile(f)
+
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to start CPU profile (path=%s): %w", cpuPath, err)
+		}
+
+		prof.cpuFile = f
+	}
+
+	return prof, nil
+}
+
+func (prof *profiler) stop() error {
+	if prof.cpuFile != nil {
+		pprof.StopCPUProfile()
+		err := prof.cpuFile.Close()
+		prof.cpuFile = nil
+
+		if err != nil {
+			return fmt.Errorf("failed to write CPU profile: %w", err)
+		}
+	}
+
+	if prof.memPath != "" {
+		f, err := os.Create(prof.memPath)
+
+		if err != nil {
+			return fmt.Errorf("failed to create memory profile (path=%s): %w", prof.memPath, err)
+		}
+
+		defer f.Close()
+
+		// Get up-to-date statistics of the allocations
+		runtime.GC()
+		err = pprof.WriteHeapProfile(f)
+
+		if err != nil {
+			return fmt.Errorf("failed to write memory profile (path=%s): %w", prof.memPath, err)
+		}
+	}
+
+	return nil
+}
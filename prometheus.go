@@ -0,0 +1,215 @@
+package rsslap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgconn"
+)
+
+const (
+	PrometheusShutdownTimeout = 5 * time.Second
+	ErrorTypeTimeout          = "timeout"
+)
+
+// Upper bounds (sec) of the buckets of 'rsslap_latency_seconds'
+var PrometheusLatencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type promQueryKey struct {
+	agent  int
+	status string
+}
+
+type promErrorKey struct {
+	agent   int
+	errType string
+}
+
+// Exposes the samples of the recorder at '/metrics' in the Prometheus text format.
+type PrometheusRecorder struct {
+	sync.Mutex
+	server       *http.Server
+	loadType     string
+	agentLabel   bool
+	activeAgents int
+	qps          float64
+	queries      map[promQueryKey]uint64
+	errors       map[promErrorKey]uint64
+	bucketCnts   []uint64
+	latencySum   float64
+	latencyCount uint64
+}
+
+func startPrometheusRecorder(addr string, loadType string, agentLabel bool) (*PrometheusRecorder, error) {
+	ln, err := net.Listen("tcp", addr)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for Prometheus metrics (addr=%s): %w", addr, err)
+	}
+
+	pr := &PrometheusRecorder{
+		loadType:   loadType,
+		agentLabel: agentLabel,
+		queries:    map[promQueryKey]uint64{},
+		errors:     map[promErrorKey]uint64{},
+		bucketCnts: make([]uint64, len(PrometheusLatencyBuckets)),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", pr.serveMetrics)
+	pr.server = &http.Server{Handler: mux}
+
+	go func() {
+		if err := pr.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintf(os.Stderr, "\n[WARN] Prometheus metrics server error: %s\n", err)
+		}
+	}()
+
+	return pr, nil
+}
+
+func (pr *PrometheusRecorder) observe(recDps []recorderDataPoint, rec *Recorder) {
+	pr.Lock()
+	defer pr.Unlock()
+
+	for _, v := range recDps {
+		agent := int(v.agentId)
+
+		// NOTE: Aggregate all agents to limit the cardinality
+		if !pr.agentLabel {
+			agent = -1
+		}
+
+		if v.failed {
+			pr.queries[promQueryKey{agent, "error"}]++
+			code, _ := rec.errorKind(v.errKind)
+			pr.errors[promErrorKey{agent, code}]++
+			continue
+		}
+
+		pr.queries[promQueryKey{agent, "success"}]++
+		sec := v.resTime.Seconds()
+
+		for i, le := range PrometheusLatencyBuckets {
+			if sec <= le {
+				pr.bucketCnts[i]++
+			}
+		}
+
+		pr.latencySum += sec
+		pr.latencyCount++
+	}
+}
+
+func (pr *PrometheusRecorder) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	pr.Lock()
+	defer pr.Unlock()
+	sb := strings.Builder{}
+
+	sb.WriteString("# HELP rsslap_queries_total Number of executed queries.\n")
+	sb.WriteString("# TYPE rsslap_queries_total counter\n")
+	queryKeys := make([]promQueryKey, 0, len(pr.queries))
+
+	for k := range pr.queries {
+		queryKeys = append(queryKeys, k)
+	}
+
+	sort.Slice(queryKeys, func(i, j int) bool {
+		return queryKeys[i].agent < queryKeys[j].agent || queryKeys[i].agent == queryKeys[j].agent && queryKeys[i].status < queryKeys[j].status
+	})
+
+	for _, k := range queryKeys {
+		fmt.Fprintf(&sb, "rsslap_queries_total{%sstatus=\"%s\"} %d\n", pr.labels(k.agent), k.status, pr.queries[k])
+	}
+
+	sb.WriteString("# HELP rsslap_errors_total Number of failed queries.\n")
+	sb.WriteString("# TYPE rsslap_errors_total counter\n")
+	errorKeys := make([]promErrorKey, 0, len(pr.errors))
+
+	for k := range pr.errors {
+		errorKeys = append(errorKeys, k)
+	}
+
+	sort.Slice(errorKeys, func(i, j int) bool {
+		return errorKeys[i].agent < errorKeys[j].agent || errorKeys[i].agent == errorKeys[j].agent && errorKeys[i].errType < errorKeys[j].errType
+	})
+
+	for _, k := range errorKeys {
+		fmt.Fprintf(&sb, "rsslap_errors_total{%serror_type=\"%s\"} %d\n", pr.labels(k.agent), k.errType, pr.errors[k])
+	}
+
+	sb.WriteString("# HELP rsslap_latency_seconds Response time of the successful queries.\n")
+	sb.WriteString("# TYPE rsslap_latency_seconds histogram\n")
+
+	for i, le := range PrometheusLatencyBuckets {
+		fmt.Fprintf(&sb, "rsslap_latency_seconds_bucket{%sle=\"%s\"} %d\n", pr.labels(-1), strconv.FormatFloat(le, 'g', -1, 64), pr.bucketCnts[i])
+	}
+
+	fmt.Fprintf(&sb, "rsslap_latency_seconds_bucket{%sle=\"+Inf\"} %d\n", pr.labels(-1), pr.latencyCount)
+	fmt.Fprintf(&sb, "rsslap_latency_seconds_sum{%s} %s\n", strings.TrimSuffix(pr.labels(-1), ","), strconv.FormatFloat(pr.latencySum, 'g', -1, 64))
+	fmt.Fprintf(&sb, "rsslap_latency_seconds_count{%s} %d\n", strings.TrimSuffix(pr.labels(-1), ","), pr.latencyCount)
+
+	sb.WriteString("# HELP rsslap_active_agents Number of running agents.\n")
+	sb.WriteString("# TYPE rsslap_active_agents gauge\n")
+	fmt.Fprintf(&sb, "rsslap_active_agents{%s} %d\n", strings.TrimSuffix(pr.labels(-1), ","), pr.activeAgents)
+
+	sb.WriteString("# HELP rsslap_qps Number of queries executed in the last second.\n")
+	sb.WriteString("# TYPE rsslap_qps gauge\n")
+	fmt.Fprintf(&sb, "rsslap_qps{%s} %s\n", strings.TrimSuffix(pr.labels(-1), ","), strconv.FormatFloat(pr.qps, 'g', -1, 64))
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = w.Write([]byte(sb.String()))
+}
+
+// Labels followed by a comma. A negative agent ID omits the 'agent' label.
+func (pr *PrometheusRecorder) labels(agent int) string {
+	labels := fmt.Sprintf("load_type=\"%s\",", pr.loadType)
+
+	if agent >= 0 {
+		labels += fmt.Sprintf("agent=\"%d\",", agent)
+	}
+
+	return labels
+}
+
+func (pr *PrometheusRecorder) setGauges(activeAgents int, qps float64) {
+	pr.Lock()
+	defer pr.Unlock()
+	pr.activeAgents = activeAgents
+	pr.qps = qps
+}
+
+func (pr *PrometheusRecorder) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), PrometheusShutdownTimeout)
+	defer cancel()
+
+	if err := pr.server.Shutdown(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "[WARN] Failed to shut down Prometheus metrics server: %s\n", err)
+	}
+}
+
+// Classify the error of a failed query for the 'error_type' label, e.g. the SQLSTATE code.
+func errorType(err error) string {
+	var pgErr *pgconn.PgError
+
+	if errors.Is(err, errQueryTimeout) {
+		return ErrorTypeTimeout
+	} else if errors.As(err, &pgErr) {
+		return pgErr.Code
+	} else if pgconn.Timeout(err) {
+		return ErrorTypeTimeout
+	} else if errors.Is(err, context.Canceled) {
+		return "canceled"
+	}
+
+	return "other"
+}
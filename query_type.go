@@ -0,0 +1,165 @@
+package rsslap
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Statistics of each kind of query, e.g. "key" and "write" in 'mixed' load type,
+// the index of the query in '--query(-q)' like "query#2", or the statement type like "select".
+type QueryTypeReport struct {
+	Type       string
+	Count      int
+	ErrorCount int
+	QPS        float64
+	// Percentages of the executions and of the weight among the queries of '--query-distribution weighted'
+	Share           float64 `json:",omitempty"`
+	ConfiguredShare float64 `json:",omitempty"`
+	Response        *ResponseMetrics
+}
+
+func (qtr *QueryTypeReport) summary() string {
+	s := fmt.Sprintf("count=%d errors=%d qps=%.1f", qtr.Count, qtr.ErrorCount, qtr.QPS)
+
+	if qtr.ConfiguredShare > 0 {
+		s += fmt.Sprintf(" share=%.1f%% configured=%.1f%%", qtr.Share, qtr.ConfiguredShare)
+	}
+
+	return s
+}
+
+func (rec *Recorder) queryTypes(elapsed time.Duration) []QueryTypeReport {
+	reports := rec.groupResponses(elapsed, func(v *recorderDataPoint) int32 { return v.queryType }, rec.strs.get)
+
+	if rec.QueryDistribution == QueryDistributionWeighted {
+		rec.weightShares(reports)
+	}
+
+	return reports
+}
+
+// Compare the actual mix of the weighted queries, including the failed ones, with the weights.
+func (rec *Recorder) weightShares(reports []QueryTypeReport) {
+	total := 0
+	totalWeight := 0
+
+	for _, qtr := range reports {
+		total += qtr.Count + qtr.ErrorCount
+	}
+
+	for _, w := range rec.QueryWeights {
+		totalWeight += w
+	}
+
+	if total == 0 || totalWeight == 0 {
+		return
+	}
+
+	for i := range reports {
+		n, ok := queryTypeIndex(reports[i].Type)
+
+		if !ok || n < 1 || n > len(rec.QueryWeights) {
+			continue
+		}
+
+		reports[i].Share = float64(reports[i].Count+reports[i].ErrorCount) * 100 / float64(total)
+		reports[i].ConfiguredShare = float64(rec.QueryWeights[n-1]) * 100 / float64(totalWeight)
+	}
+}
+
+func (rec *Recorder) statementTypes(elapsed time.Duration) []QueryTypeReport {
+	stmtTypes := newStringTable()
+	stmtTypeByQuery := map[int32]int32{}
+
+	return rec.groupResponses(elapsed, func(v *recorderDataPoint) int32 {
+		idx, ok := stmtTypeByQuery[v.query]
+
+		if !ok {
+			idx = stmtTypes.index(statementType(rec.strs.get(v.query)))
+			stmtTypeByQuery[v.query] = idx
+		}
+
+		return idx
+	}, stmtTypes.get)
+}
+
+// Report the response times of the groups of the data points by the index of the key, e.g. of the query type.
+// NOTE: The response times are partitioned by the group in a single slice, instead of a slice of each group
+func (rec *Recorder) groupResponses(elapsed time.Duration, key func(v *recorderDataPoint) int32, label func(idx int32) string) []QueryTypeReport {
+	counts := map[int32]int{}
+	errCnts := map[int32]int{}
+
+	for i := range rec.dataPoints {
+		counts[key(&rec.dataPoints[i])]++
+	}
+
+	for i := range rec.errorDataPoints {
+		k := key(&rec.errorDataPoints[i])
+		errCnts[k]++
+		counts[k] += 0
+	}
+
+	offsets := make(map[int32]int, len(counts))
+	next := make(map[int32]int, len(counts))
+	offset := 0
+
+	for k, cnt := range counts {
+		offsets[k] = offset
+		next[k] = offset
+		offset += cnt
+	}
+
+	resTimes := make([]time.Duration, len(rec.dataPoints))
+
+	for i := range rec.dataPoints {
+		k := key(&rec.dataPoints[i])
+		resTimes[next[k]] = rec.dataPoints[i].resTime
+		next[k]++
+	}
+
+	reports := make([]QueryTypeReport, 0, len(counts))
+
+	for k, cnt := range counts {
+		rts := resTimes[offsets[k] : offsets[k]+cnt]
+		qtr := QueryTypeReport{
+			Type:       label(k),
+			Count:      cnt,
+			ErrorCount: errCnts[k],
+			Response:   newResponseMetrics(rts, rec.HInterval, rec.Percentiles),
+		}
+
+		if elapsed > 0 {
+			qtr.QPS = float64(cnt) * float64(time.Second) / float64(elapsed)
+		}
+
+		reports = append(reports, qtr)
+	}
+
+	sort.Slice(reports, func(i, j int) bool { return lessQueryType(reports[i].Type, reports[j].Type) })
+
+	return reports
+}
+
+// Order the kinds by the name, but the queries of '--query(-q)' by the index, e.g. "query#2" before "query#10".
+func lessQueryType(a string, b string) bool {
+	if n, ok := queryTypeIndex(a); ok {
+		if m, ok := queryTypeIndex(b); ok {
+			return n < m
+		}
+	}
+
+	return a < b
+}
+
+// Return the 1-based index of the query of a kind like "query#2".
+func queryTypeIndex(queryType string) (int, bool) {
+	if !strings.HasPrefix(queryType, QueryTypeQueryPrefix) {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(strings.TrimPrefix(queryType, QueryTypeQueryPrefix))
+	return n, err == nil
+}
@@ -1,39 +1,100 @@
 package rsslap
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
 	"runtime"
 	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
-
-	"github.com/winebarrel/tachymeter"
 )
 
 type recorderDataPoint struct {
 	timestamp time.Time
 	resTime   time.Duration
+	agentId   int32
+	// Number of the query in the agent
+	queryIdx int32
+	// Indexes of Recorder.strs of the SQL, the kind of the query (e.g. "key" or "query#2") and the tag
+	query     int32
+	queryType int32
+	queryTag  int32
+	// Index of Recorder.errorKinds of the code and the message of the error
+	errKind  int32
+	failed   bool
+	timedOut bool
 }
 
 type RecorderReport struct {
+	// Schema version of the JSON report, see ReportVersion
+	Version     int
 	URL         string
+	CommandLine string
+	ConfigHash  string
 	StartedAt   time.Time
 	FinishedAt  time.Time
 	ElapsedTime time.Duration
 	TaskOpts
 	DataOpts
-	GOMAXPROCS  int
-	QueryCount  int
-	AvgQPS      float64
-	MaxQPS      float64
-	MinQPS      float64
-	MedianQPS   float64
-	ExpectedQPS int
-	Response    *tachymeter.Metrics
+	GOMAXPROCS                  int
+	QueryCount                  int
+	ErrorCount                  int
+	TimeoutCount                int           `json:",omitempty"`
+	Errors                      []ErrorDetail `json:",omitempty"`
+	DroppedErrorDetailCount     int           `json:",omitempty"`
+	AvgQPS                      float64
+	MaxQPS                      float64
+	MinQPS                      float64
+	MedianQPS                   float64
+	ExpectedQPS                 float64
+	DeferredAgents              int
+	AbandonedCount              int
+	RetryCount                  int
+	UnmeasuredQueryCount        int `json:",omitempty"`
+	DrainedQueryCount           int `json:",omitempty"`
+	DrainAbandonedCount         int `json:",omitempty"`
+	ResultMemLimitExceededCount int
+	AbortReason                 string
+	SLA                         *SLAReport         `json:",omitempty"`
+	ConnectTime                 *ConnectTimeReport `json:",omitempty"`
+	ResultChecksum              string             `json:",omitempty"`
+	ResultRows                  int64              `json:",omitempty"`
+	BytesWritten                int64              `json:",omitempty"`
+	BytesWrittenPerSec          float64            `json:",omitempty"`
+	Response                    *ResponseMetrics
+	ErrorResponse               *ResponseMetrics  `json:",omitempty"`
+	ColdWarm                    []ColdWarmReport  `json:",omitempty"`
+	Steps                       []StepReport      `json:",omitempty"`
+	Phases                      []PhaseReport     `json:",omitempty"`
+	QueryTypes                  []QueryTypeReport `json:",omitempty"`
+	StatementTypes              []QueryTypeReport `json:",omitempty"`
+	Tags                        []QueryTypeReport `json:",omitempty"`
 }
 
 type RecorderOpts struct {
-	URL       string
-	HInterval time.Duration
+	URL                 string
+	CommandLine         []string
+	HInterval           time.Duration
+	SamplesSocket       string
+	OutputFormat        OutputFormat
+	LatencyCSV          string
+	CSVOutput           string
+	Hgrm                string
+	IntervalReport      time.Duration
+	IntervalReportFile  string
+	PrometheusAddr      string
+	MetricsNoAgentLabel bool
+	StatsdAddr          string
+	StatsdPrefix        string
+	Percentiles         []float64
+	MaxErrorDetail      int
+	ColdWarm            bool
+	SLAP99              time.Duration
+	SLAErrorRate        float64
 }
 
 type Recorder struct {
@@ -41,10 +102,37 @@ type Recorder struct {
 	RecorderOpts
 	TaskOpts
 	DataOpts
-	startedAt  time.Time
-	finishedAt time.Time
-	channel    chan []recorderDataPoint
-	dataPoints []recorderDataPoint
+	startedAt      time.Time
+	finishedAt     time.Time
+	abortReason    string
+	deferredAgents int
+	checksum       uint64
+	abandonCnt     int
+	retryCnt       int
+	unmeasuredCnt  int
+	// Time to establish the initial connection of each agent
+	connectTimes []time.Duration
+	// for '--drain'
+	drainedCnt        int
+	drainAbandonedCnt int
+	// Closed when all agents finish the '--warmup-queries', nil without them
+	measuring         chan struct{}
+	warmupAgents      int
+	resultMemLimitCnt int
+	rowCnt            int64
+	bytesWritten      int64
+	channel           chan []recorderDataPoint
+	done              chan struct{}
+	dataPoints        []recorderDataPoint
+	errorDataPoints   []recorderDataPoint
+	strs              *stringTable
+	errorKinds        *stringTable
+	samples           *sampleStream
+	latencyCSV        *latencyCSV
+	timeSeriesCSV     *timeSeriesCSV
+	intervalReport    *intervalReport
+	prometheus        *PrometheusRecorder
+	statsd            *statsdEmitter
 }
 
 func newRecorder(recOpts *RecorderOpts, taskOpts *TaskOpts, dataOpts *DataOpts) (rec *Recorder) {
@@ -52,34 +140,277 @@ func newRecorder(recOpts *RecorderOpts, taskOpts *TaskOpts, dataOpts *DataOpts)
 		RecorderOpts: *recOpts,
 		TaskOpts:     *taskOpts,
 		DataOpts:     *dataOpts,
+		strs:         newStringTable(),
+		errorKinds:   newStringTable(),
 	}
 
 	return
 }
 
-func (rec *Recorder) start(bufsize int) {
+func (rec *Recorder) start(bufsize int) error {
 	rec.dataPoints = []recorderDataPoint{}
+	rec.errorDataPoints = []recorderDataPoint{}
 	ch := make(chan []recorderDataPoint, bufsize)
 	rec.channel = ch
+	rec.done = make(chan struct{})
+
+	if rec.SamplesSocket != "" {
+		samples, err := openSampleStream(rec.SamplesSocket)
+
+		if err != nil {
+			return err
+		}
+
+		rec.samples = samples
+	}
+
+	if rec.LatencyCSV != "" {
+		latencyCSV, err := openLatencyCSV(rec.LatencyCSV)
+
+		if err != nil {
+			return err
+		}
+
+		rec.latencyCSV = latencyCSV
+	}
+
+	if rec.CSVOutput != "" {
+		timeSeriesCSV, err := openTimeSeriesCSV(rec.CSVOutput)
+
+		if err != nil {
+			return err
+		}
+
+		rec.timeSeriesCSV = timeSeriesCSV
+	}
+
+	if rec.IntervalReport > 0 {
+		intervalReport, err := openIntervalReport(rec.IntervalReport, rec.IntervalReportFile)
+
+		if err != nil {
+			return err
+		}
+
+		rec.intervalReport = intervalReport
+	}
+
+	if rec.PrometheusAddr != "" {
+		prometheus, err := startPrometheusRecorder(rec.PrometheusAddr, rec.metricsLoadType(), !rec.MetricsNoAgentLabel)
+
+		if err != nil {
+			return err
+		}
+
+		rec.prometheus = prometheus
+	}
+
+	if rec.StatsdAddr != "" {
+		rec.statsd = openStatsdEmitter(rec.StatsdAddr, rec.StatsdPrefix)
+	}
+
+	// NOTE: Samples collected during the ramp-up and the warm-up are discarded
+	rec.startedAt = time.Now().Add(rec.unmeasured())
+
+	if rec.WarmupQueries > 0 {
+		rec.measuring = make(chan struct{})
+		rec.warmupAgents = rec.NAgents
+	}
 
 	go func() {
+		pending := [][]recorderDataPoint{}
+
 		for redDps := range ch {
-			rec.appendDataPoints(redDps)
+			// NOTE: Hold the samples until the start of the measurement is known
+			if !rec.isMeasuring() {
+				pending = append(pending, redDps)
+				continue
+			}
+
+			for _, v := range pending {
+				rec.process(v)
+			}
+
+			pending = nil
+			rec.process(redDps)
+		}
+
+		// The test finished during the warm-up queries
+		for _, v := range pending {
+			rec.addUnmeasured(len(v))
+		}
+
+		if rec.samples != nil {
+			rec.samples.close()
+		}
+
+		if rec.latencyCSV != nil {
+			if err := rec.latencyCSV.close(); err != nil {
+				fmt.Fprintf(os.Stderr, "[WARN] Failed to close latency CSV: %s\n", err)
+			}
+		}
+
+		if rec.timeSeriesCSV != nil {
+			if err := rec.timeSeriesCSV.close(); err != nil {
+				fmt.Fprintf(os.Stderr, "[WARN] Failed to close time series CSV: %s\n", err)
+			}
+		}
+
+		if rec.intervalReport != nil {
+			if err := rec.intervalReport.close(rec.startedAt); err != nil {
+				fmt.Fprintf(os.Stderr, "[WARN] Failed to write interval report: %s\n", err)
+			}
 		}
+
+		if rec.prometheus != nil {
+			rec.prometheus.shutdown()
+		}
+
+		if rec.statsd != nil {
+			rec.statsd.close()
+		}
+
+		close(rec.done)
 	}()
 
-	rec.startedAt = time.Now()
+	return nil
+}
+
+// Load type for the 'load_type' label of the metrics.
+func (rec *Recorder) metricsLoadType() string {
+	if len(rec.Queries) > 0 {
+		return "query"
+	} else if rec.CallProc != "" {
+		return "call"
+	}
+
+	return string(rec.LoadType)
+}
+
+// Update the gauges of the metrics, if exposed.
+func (rec *Recorder) updateGauges(activeAgents int, qps float64) {
+	if rec.prometheus != nil {
+		rec.prometheus.setGauges(activeAgents, qps)
+	}
+}
+
+// QPS limited by '--global-rate' or '--rate', or zero if unlimited.
+func (rec *Recorder) expectedQPS() float64 {
+	if rec.GlobalRate > 0 {
+		return rec.GlobalRate
+	}
+
+	return float64(rec.NAgents) * rec.Rate
+}
+
+func (rec *Recorder) process(recDps []recorderDataPoint) {
+	if rec.unmeasured() > 0 || rec.WarmupQueries > 0 {
+		recDps = rec.dropWarmup(recDps)
+	}
+
+	rec.writeSamples(recDps)
+	rec.writeLatencyCSV(recDps)
+	rec.writeTimeSeriesCSV(recDps)
+	rec.writeIntervalReport(recDps)
+
+	if rec.prometheus != nil {
+		rec.prometheus.observe(recDps, rec)
+	}
+
+	if rec.statsd != nil {
+		rec.statsd.emit(recDps)
+	}
+
+	rec.appendDataPoints(recDps)
+}
+
+// An agent finished its '--warmup-queries'. The measurement starts when the last agent does.
+func (rec *Recorder) endWarmupQueries() {
+	rec.Lock()
+	defer rec.Unlock()
+	rec.warmupAgents--
+
+	if rec.warmupAgents == 0 {
+		if now := time.Now(); now.After(rec.startedAt) {
+			rec.startedAt = now
+		}
+
+		close(rec.measuring)
+	}
+}
+
+// Start of the measured window, which is in the future during the ramp-up and the warm-up.
+func (rec *Recorder) measureStart() time.Time {
+	rec.Lock()
+	defer rec.Unlock()
+	return rec.startedAt
+}
+
+func (rec *Recorder) isMeasuring() bool {
+	if rec.measuring == nil {
+		return true
+	}
+
+	select {
+	case <-rec.measuring:
+		return true
+	default:
+		return false
+	}
+}
+
+// Drop the samples before the start of the measurement, counting them for the report.
+func (rec *Recorder) dropWarmup(recDps []recorderDataPoint) []recorderDataPoint {
+	for i, v := range recDps {
+		if !v.timestamp.Before(rec.startedAt) {
+			rec.addUnmeasured(i)
+			return recDps[i:]
+		}
+	}
+
+	rec.addUnmeasured(len(recDps))
+	return recDps[:0]
+}
+
+func (rec *Recorder) addUnmeasured(cnt int) {
+	rec.Lock()
+	defer rec.Unlock()
+	rec.unmeasuredCnt += cnt
 }
 
 func (rec *Recorder) appendDataPoints(recDps []recorderDataPoint) {
 	rec.Lock()
 	defer rec.Unlock()
-	rec.dataPoints = append(rec.dataPoints, recDps...)
+
+	for _, v := range recDps {
+		if v.failed {
+			rec.errorDataPoints = append(rec.errorDataPoints, v)
+		} else {
+			rec.dataPoints = append(rec.dataPoints, v)
+		}
+	}
 }
 
 func (rec *Recorder) close() {
 	close(rec.channel)
 	rec.finishedAt = time.Now()
+
+	// The test finished during the warm-up
+	if rec.finishedAt.Before(rec.startedAt) || !rec.isMeasuring() {
+		rec.startedAt = rec.finishedAt
+	}
+	<-rec.done
+
+	if rec.Hgrm != "" {
+		resTimes := make([]time.Duration, len(rec.dataPoints))
+
+		for i, v := range rec.dataPoints {
+			resTimes[i] = v.resTime
+		}
+
+		if err := writeHgrm(rec.Hgrm, resTimes); err != nil {
+			fmt.Fprintf(os.Stderr, "[WARN] %s\n", err)
+		}
+	}
 }
 
 func (rec *Recorder) qpsHist() []float64 {
@@ -144,6 +475,49 @@ func (rec *Recorder) qps() (minQPS float64, maxQPS float64, medianQPS float64) {
 	return
 }
 
+func (rec *Recorder) abort(reason string) {
+	rec.Lock()
+	defer rec.Unlock()
+	rec.abortReason = reason
+}
+
+func (rec *Recorder) addChecksum(checksum uint64, rowCnt int64) {
+	rec.Lock()
+	defer rec.Unlock()
+	rec.checksum += checksum
+	rec.rowCnt += rowCnt
+}
+
+func (rec *Recorder) addAbandoned(cnt int) {
+	rec.Lock()
+	defer rec.Unlock()
+	rec.abandonCnt += cnt
+}
+
+func (rec *Recorder) addDrained(completed int, abandoned int) {
+	rec.Lock()
+	defer rec.Unlock()
+	rec.drainedCnt += completed
+	rec.drainAbandonedCnt += abandoned
+}
+
+func (rec *Recorder) addRetries(cnt int) {
+	rec.Lock()
+	defer rec.Unlock()
+	rec.retryCnt += cnt
+}
+
+func (rec *Recorder) addResultMemLimitExceeded(cnt int) {
+	rec.Lock()
+	defer rec.Unlock()
+	rec.resultMemLimitCnt += cnt
+}
+
+// Add the estimated bytes inserted by an agent, and return the total of all agents.
+func (rec *Recorder) addBytesWritten(n int64) int64 {
+	return atomic.AddInt64(&rec.bytesWritten, n)
+}
+
 func (rec *Recorder) add(recDps []recorderDataPoint) {
 	rec.channel <- recDps
 }
@@ -153,34 +527,119 @@ func (rec *Recorder) Report() (rr *RecorderReport) {
 	queryCnt := rec.Count()
 
 	rr = &RecorderReport{
-		URL:         rec.URL,
-		StartedAt:   rec.startedAt,
-		FinishedAt:  rec.finishedAt,
-		ElapsedTime: nanoElapsed / time.Second,
-		TaskOpts:    rec.TaskOpts,
-		DataOpts:    rec.DataOpts,
-		GOMAXPROCS:  runtime.GOMAXPROCS(0),
-		QueryCount:  queryCnt,
-		AvgQPS:      float64(queryCnt) * float64(time.Second) / float64(nanoElapsed),
-		ExpectedQPS: rec.NAgents * rec.Rate,
-	}
-
-	t := tachymeter.New(&tachymeter.Config{
-		Size:      len(rec.dataPoints),
-		HBins:     10,
-		HInterval: rec.HInterval,
-	})
+		Version:                     ReportVersion,
+		URL:                         rec.URL,
+		CommandLine:                 strings.Join(rec.CommandLine, " "),
+		ConfigHash:                  configHash(rec.CommandLine),
+		StartedAt:                   rec.startedAt,
+		FinishedAt:                  rec.finishedAt,
+		ElapsedTime:                 nanoElapsed / time.Second,
+		TaskOpts:                    rec.TaskOpts,
+		DataOpts:                    rec.DataOpts,
+		GOMAXPROCS:                  runtime.GOMAXPROCS(0),
+		QueryCount:                  queryCnt,
+		ExpectedQPS:                 rec.expectedQPS(),
+		AbortReason:                 rec.abortReason,
+		DeferredAgents:              rec.deferredAgents,
+		AbandonedCount:              rec.abandonCnt,
+		RetryCount:                  rec.retryCnt,
+		UnmeasuredQueryCount:        rec.unmeasuredCnt,
+		DrainedQueryCount:           rec.drainedCnt,
+		DrainAbandonedCount:         rec.drainAbandonedCnt,
+		ResultMemLimitExceededCount: rec.resultMemLimitCnt,
+	}
+
+	if rec.ChecksumResults {
+		rr.ResultChecksum = fmt.Sprintf("%016x", rec.checksum)
+		rr.ResultRows = rec.rowCnt
+	}
 
-	for _, v := range rec.dataPoints {
-		t.AddTime(v.resTime)
+	if nanoElapsed > 0 {
+		rr.AvgQPS = float64(queryCnt) * float64(time.Second) / float64(nanoElapsed)
 	}
 
-	rr.Response = t.Calc()
+	if bytesWritten := atomic.LoadInt64(&rec.bytesWritten); bytesWritten > 0 && nanoElapsed > 0 {
+		rr.BytesWritten = bytesWritten
+		rr.BytesWrittenPerSec = float64(bytesWritten) * float64(time.Second) / float64(nanoElapsed)
+	}
+
+	rr.Response = rec.responseMetrics(rec.dataPoints)
+
+	if len(rec.errorDataPoints) > 0 {
+		rr.ErrorCount = len(rec.errorDataPoints)
+		rr.TimeoutCount = rec.timeoutCount()
+		rr.Errors, rr.DroppedErrorDetailCount = rec.errorDetails(rec.MaxErrorDetail)
+		rr.ErrorResponse = rec.responseMetrics(rec.errorDataPoints)
+	}
 	rr.MinQPS, rr.MaxQPS, rr.MedianQPS = rec.qps()
 
+	rr.QueryTypes = rec.queryTypes(nanoElapsed)
+	rr.StatementTypes = rec.statementTypes(nanoElapsed)
+
+	if rec.QueryTags != nil {
+		rr.Tags = rec.groupResponses(nanoElapsed, func(v *recorderDataPoint) int32 { return v.queryTag }, rec.strs.get)
+	}
+
+	if rec.StepAgents > 0 {
+		rr.Steps = rec.stepReports()
+	}
+
+	if len(rec.Schedule) > 0 {
+		rr.Phases = rec.phaseReports()
+	}
+
+	if rec.ColdWarm {
+		rr.ColdWarm = rec.coldWarm()
+	}
+
+	rr.SLA = rec.slaReport(rr)
+	rr.ConnectTime = rec.connectTimeReport()
+
 	return
 }
 
+func (rec *Recorder) responseMetrics(recDps []recorderDataPoint) *ResponseMetrics {
+	resTimes := make([]time.Duration, len(recDps))
+
+	for i, v := range recDps {
+		resTimes[i] = v.resTime
+	}
+
+	return newResponseMetrics(resTimes, rec.HInterval, rec.Percentiles)
+}
+
+// Flags of the outputs, the profiles and the SLA, which do not change the workload and are not in ConfigHash.
+var configHashExcludedFlags = map[string]bool{
+	"format": true, "output-format": true, "output": true, "output-append": true, "output-label": true,
+	"max-error-detail": true, "percentiles": true, "hinterval": true, "sla-p99": true, "sla-error-rate": true,
+	"cpuprofile": true, "memprofile": true, "no-progress": true, "dashboard": true, "live-histogram": true,
+	"latency-csv": true, "latency-log": true, "csv-output": true, "hgrm": true, "samples-socket": true,
+	"interval-report": true, "interval-report-file": true, "prometheus-addr": true, "metrics-listen": true,
+	"metrics-no-agent-label": true, "statsd-addr": true, "statsd-prefix": true, "config": true,
+}
+
+// Hash of the effective options, to group the reports of runs with the same configuration.
+// NOTE: The flags of configHashExcludedFlags are skipped with their values
+func configHash(commandLine []string) string {
+	h := sha256.New()
+	skipping := false
+
+	for _, arg := range commandLine {
+		if strings.HasPrefix(arg, "--") {
+			skipping = configHashExcludedFlags[strings.TrimPrefix(arg, "--")]
+		}
+
+		if skipping {
+			continue
+		}
+
+		h.Write([]byte(arg))
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
 func (rec *Recorder) Count() int {
 	rec.Lock()
 	defer rec.Unlock()
@@ -0,0 +1,110 @@
+package rsslap
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// The groups of the interned query types get the response times of their own data points.
+func TestGroupResponses(t *testing.T) {
+	rec := newRecorder(&RecorderOpts{}, &TaskOpts{}, &DataOpts{})
+	key := rec.strs.index("key")
+	write := rec.strs.index("write")
+
+	for i := 1; i <= 5; i++ {
+		rec.dataPoints = append(rec.dataPoints,
+			recorderDataPoint{resTime: time.Duration(i) * time.Millisecond, queryType: key},
+			recorderDataPoint{resTime: time.Duration(i) * time.Second, queryType: write})
+	}
+
+	failed := rec.strs.index("failed")
+	rec.errorDataPoints = append(rec.errorDataPoints, recorderDataPoint{queryType: failed, failed: true})
+
+	reports := rec.groupResponses(time.Second, func(v *recorderDataPoint) int32 { return v.queryType }, rec.strs.get)
+	want := map[string]struct {
+		count      int
+		errorCount int
+		max        time.Duration
+	}{
+		"key":    {5, 0, 5 * time.Millisecond},
+		"write":  {5, 0, 5 * time.Second},
+		"failed": {0, 1, 0},
+	}
+
+	if len(reports) != len(want) {
+		t.Fatalf("groups = %d, want %d", len(reports), len(want))
+	}
+
+	for _, qtr := range reports {
+		w := want[qtr.Type]
+
+		if qtr.Count != w.count || qtr.ErrorCount != w.errorCount || qtr.Response.Max != w.max {
+			t.Errorf("%s: count=%d errors=%d max=%s, want count=%d errors=%d max=%s", qtr.Type, qtr.Count, qtr.ErrorCount, qtr.Response.Max, w.count, w.errorCount, w.max)
+		}
+	}
+}
+
+// The error details get the code and the message from the kind of each failed data point.
+func TestErrorDetails(t *testing.T) {
+	rec := newRecorder(&RecorderOpts{}, &TaskOpts{}, &DataOpts{})
+	start := time.Now()
+	kinds := []int32{
+		rec.errorKindIndex("23505", "duplicate key"),
+		rec.errorKindIndex(ErrorTypeTimeout, "query timeout"),
+		rec.errorKindIndex("23505", "duplicate key"),
+	}
+
+	for i, k := range kinds {
+		rec.errorDataPoints = append(rec.errorDataPoints, recorderDataPoint{
+			timestamp: start.Add(time.Duration(i) * time.Second),
+			queryType: rec.strs.index("key"),
+			errKind:   k,
+			failed:    true,
+			timedOut:  i == 1,
+		})
+	}
+
+	details, dropped := rec.errorDetails(DefaultMaxErrorDetail)
+
+	if len(details) != 2 || dropped != 0 {
+		t.Fatalf("details = %v (dropped=%d), want 2 kinds", details, dropped)
+	}
+
+	if d := details[0]; d.Code != "23505" || d.Name != "unique_violation" || d.Message != "duplicate key" || d.Count != 2 || d.FirstQueryType != "key" {
+		t.Errorf("details[0] = %+v", d)
+	}
+
+	if got := rec.timeoutCount(); got != 1 {
+		t.Errorf("timeoutCount() = %d, want 1", got)
+	}
+}
+
+// The queries of '--query(-q)' are ordered by the index, not by the name.
+func TestGroupResponsesOrder(t *testing.T) {
+	rec := newRecorder(&RecorderOpts{}, &TaskOpts{}, &DataOpts{})
+	data := newData(&DataOpts{Queries: make([]string, 12)}, nil, 1)
+
+	for _, qt := range append([]string{"write", "key"}, data.queryTypes...) {
+		rec.dataPoints = append(rec.dataPoints, recorderDataPoint{resTime: time.Millisecond, queryType: rec.strs.index(qt)})
+	}
+
+	reports := rec.groupResponses(time.Second, func(v *recorderDataPoint) int32 { return v.queryType }, rec.strs.get)
+	want := []string{"key"}
+
+	for i := 1; i <= 12; i++ {
+		want = append(want, fmt.Sprintf("query#%d", i))
+	}
+
+	want = append(want, "write")
+	got := []string{}
+
+	for _, qtr := range reports {
+		got = append(got, qtr.Type)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+}
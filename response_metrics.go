@@ -0,0 +1,173 @@
+package rsslap
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	HistogramBins = 10
+)
+
+// Response time statistics, with all durations in nanoseconds.
+type ResponseMetrics struct {
+	Samples     int
+	Cumulative  time.Duration
+	HMean       time.Duration
+	Avg         time.Duration
+	P50         time.Duration
+	P75         time.Duration
+	P90         time.Duration
+	P95         time.Duration
+	P99         time.Duration
+	P999        time.Duration
+	Long5p      time.Duration
+	Short5p     time.Duration
+	Max         time.Duration
+	Min         time.Duration
+	Range       time.Duration
+	StdDev      time.Duration
+	RatePerSec  float64
+	Percentiles []Percentile
+	Histogram   []HistogramBucket
+}
+
+// Nearest-rank percentile of the response times, e.g. Percentile=99.9.
+type Percentile struct {
+	Percentile float64
+	Value      time.Duration
+}
+
+// Number of response times in [From, To). The last bucket also includes To.
+// Percentile is the percentage of the response times below To.
+type HistogramBucket struct {
+	From       time.Duration
+	To         time.Duration
+	Count      int
+	Percentile float64
+}
+
+// NOTE: Sorts the response times in place, so that no other copy of the samples is made.
+func newResponseMetrics(resTimes []time.Duration, hInterval time.Duration, percentiles []float64) *ResponseMetrics {
+	m := &ResponseMetrics{
+		Samples:     len(resTimes),
+		Percentiles: make([]Percentile, len(percentiles)),
+		Histogram:   []HistogramBucket{},
+	}
+
+	if len(resTimes) == 0 {
+		return m
+	}
+
+	sorted := resTimes
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var invTotal float64
+
+	for _, v := range sorted {
+		m.Cumulative += v
+		invTotal += 1 / float64(v)
+	}
+
+	n := len(sorted)
+	m.Avg = m.Cumulative / time.Duration(n)
+	m.HMean = time.Duration(float64(n) / invTotal)
+	m.Min = sorted[0]
+	m.Max = sorted[n-1]
+	m.Range = m.Max - m.Min
+	m.P50 = percentile(sorted, 50)
+	m.P75 = percentile(sorted, 75)
+	m.P90 = percentile(sorted, 90)
+	m.P95 = percentile(sorted, 95)
+	m.P99 = percentile(sorted, 99)
+	m.P999 = percentile(sorted, 99.9)
+	m.Long5p = avgDuration(sorted[int(float64(n)*0.95+0.5):], m.Max)
+	m.Short5p = avgDuration(sorted[:int(float64(n)*0.05+0.5)], m.Min)
+	var sqSum float64
+
+	for _, v := range sorted {
+		sqSum += math.Pow(float64(v-m.Avg), 2)
+	}
+
+	m.StdDev = time.Duration(math.Sqrt(sqSum / float64(n)))
+
+	if m.Cumulative > 0 {
+		m.RatePerSec = float64(n) * float64(time.Second) / float64(m.Cumulative)
+	}
+
+	// NOTE: With a few samples, the nearest rank is the sample at or above the percentile
+	for i, p := range percentiles {
+		m.Percentiles[i] = Percentile{Percentile: p, Value: percentile(sorted, p)}
+	}
+
+	m.Histogram = histogram(sorted, hInterval)
+
+	return m
+}
+
+// Average of the response times, or the default if there are too few of them.
+func avgDuration(resTimes []time.Duration, def time.Duration) time.Duration {
+	if len(resTimes) <= 1 {
+		return def
+	}
+
+	var total time.Duration
+
+	for _, v := range resTimes {
+		total += v
+	}
+
+	return total / time.Duration(len(resTimes))
+}
+
+// Split the sorted response times into buckets of the given interval starting from zero,
+// or into HistogramBins buckets between the min and max if the interval is zero.
+func histogram(sorted []time.Duration, interval time.Duration) []HistogramBucket {
+	if len(sorted) == 0 {
+		return []HistogramBucket{}
+	}
+
+	min := sorted[0]
+	max := sorted[len(sorted)-1]
+	low := time.Duration(0)
+
+	if interval <= 0 {
+		low = min
+		interval = (max - min) / HistogramBins
+
+		if interval <= 0 {
+			return []HistogramBucket{{From: min, To: max, Count: len(sorted), Percentile: 100}}
+		}
+	}
+
+	buckets := make([]HistogramBucket, HistogramBins)
+
+	for i := range buckets {
+		buckets[i].From = low + time.Duration(i)*interval
+		buckets[i].To = buckets[i].From + interval
+	}
+
+	// NOTE: The last bucket covers the rest of the response times
+	if max > buckets[HistogramBins-1].To {
+		buckets[HistogramBins-1].To = max
+	}
+
+	for _, v := range sorted {
+		i := int((v - low) / interval)
+
+		if i >= HistogramBins {
+			i = HistogramBins - 1
+		}
+
+		buckets[i].Count++
+	}
+
+	cnt := 0
+
+	for i := range buckets {
+		cnt += buckets[i].Count
+		buckets[i].Percentile = float64(cnt) * 100 / float64(len(sorted))
+	}
+
+	return buckets
+}
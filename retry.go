@@ -0,0 +1,78 @@
+package rsslap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
+)
+
+// Execute the query, reconnecting and retrying up to MaxRetries times with exponential backoff
+// while it fails with a connection-level error.
+func (agent *Agent) queryWithRetry(ctx context.Context, q string, args ...interface{}) (time.Duration, error) {
+	backoff := agent.taskOps.RetryBackoff
+
+	for retry := 0; ; retry++ {
+		var rt time.Duration
+		var err error
+
+		// NOTE: The connection is closed after a failed attempt
+		if agent.db == nil {
+			err = agent.connect(ctx)
+		}
+
+		if err == nil {
+			rt, err = agent.query(ctx, q, args...)
+		}
+
+		if err == nil || retry >= agent.taskOps.MaxRetries || !isRetryableError(err) {
+			return rt, err
+		}
+
+		agent.retryCnt++
+		fmt.Fprintf(os.Stderr, "\n[WARN] Retry query in %s (%d/%d, agent id=%d): %s\n", backoff, retry+1, agent.taskOps.MaxRetries, agent.id, err)
+
+		if agent.db != nil {
+			_ = agent.rsConfig.closeConn(agent.db)
+			agent.db = nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return rt, err
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
+
+// Whether the error is caused by the connection and not by the query itself, e.g. a syntax error.
+func isRetryableError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, errQueryTimeout) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+
+	if errors.As(err, &pgErr) {
+		// Class 08: connection exception
+		// 57P01: admin_shutdown, 57P02: crash_shutdown, 57P03: cannot_connect_now
+		return strings.HasPrefix(pgErr.Code, "08") || pgErr.Code == "57P01" || pgErr.Code == "57P02" || pgErr.Code == "57P03"
+	}
+
+	var netErr net.Error
+
+	if pgconn.SafeToRetry(err) || errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	// NOTE: pgx does not export the error of a closed connection
+	return strings.Contains(err.Error(), "conn closed")
+}
@@ -2,14 +2,27 @@ package rsslap
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 )
 
+type IsolationLevel string
+
+const (
+	IsolationLevelReadCommitted = IsolationLevel("read-committed")
+	IsolationLevelSerializable  = IsolationLevel("serializable")
+)
+
 type RsConfig struct {
 	*pgx.ConnConfig
 	OnlyPrint bool
+	// Empty to use the server default
+	IsolationLevel IsolationLevel
+	limiter        *connLimiter
 }
 
 type DB interface {
@@ -19,29 +32,124 @@ type DB interface {
 	Close(ctx context.Context) error
 }
 
-func (pgCfg *RsConfig) openAndPing() (DB, error) {
-	if pgCfg.OnlyPrint {
-		return &NullDB{}, nil
+func (pgCfg *RsConfig) openAndPing(ctx context.Context) (DB, error) {
+	conn, _, err := pgCfg.openAndPingTimed(ctx)
+	return conn, err
+}
+
+// Same as openAndPing, and also return the time to connect without the wait for '--max-connections-total'.
+func (pgCfg *RsConfig) openAndPingTimed(ctx context.Context) (DB, time.Duration, error) {
+	err := pgCfg.limiter.acquire(ctx)
+
+	if err != nil {
+		return nil, 0, err
 	}
 
-	conn, err := pgx.ConnectConfig(context.Background(), pgCfg.ConnConfig)
+	start := time.Now()
+	conn, err := pgCfg.connect(ctx)
 
 	if err != nil {
-		return nil, err
+		pgCfg.limiter.release()
+		return nil, 0, err
 	}
 
-	err = conn.Ping(context.Background())
+	return conn, time.Since(start), nil
+}
+
+func (pgCfg *RsConfig) connect(ctx context.Context) (DB, error) {
+	var conn DB
+
+	if pgCfg.OnlyPrint {
+		conn = &NullDB{}
+	} else {
+		pgxConn, err := pgx.ConnectConfig(ctx, pgCfg.ConnConfig)
+
+		if err != nil {
+			return nil, err
+		}
+
+		err = pgxConn.Ping(ctx)
+
+		if err != nil {
+			pgxConn.Close(context.Background())
+			return nil, err
+		}
+
+		conn = pgxConn
+	}
+
+	err := pgCfg.setIsolationLevel(ctx, conn)
 
 	if err != nil {
+		conn.Close(context.Background())
 		return nil, err
 	}
 
 	return conn, nil
 }
 
+// Set the isolation level of the session, if any, also for the connections of '--pool-size'.
+func (pgCfg *RsConfig) setIsolationLevel(ctx context.Context, conn DB) error {
+	if pgCfg.IsolationLevel == "" {
+		return nil
+	}
+
+	level := strings.ToUpper(strings.ReplaceAll(string(pgCfg.IsolationLevel), "-", " "))
+	_, err := conn.Exec(ctx, "SET SESSION CHARACTERISTICS AS TRANSACTION ISOLATION LEVEL "+level)
+
+	if err != nil {
+		return fmt.Errorf("failed to set isolation level (level=%s): %w", pgCfg.IsolationLevel, err)
+	}
+
+	return nil
+}
+
+func (pgCfg *RsConfig) closeConn(conn DB) error {
+	defer pgCfg.limiter.release()
+	return conn.Close(context.Background())
+}
+
 func (pgCfg *RsConfig) Copy() *RsConfig {
 	return &RsConfig{
-		ConnConfig: pgCfg.ConnConfig.Copy(),
-		OnlyPrint:  pgCfg.OnlyPrint,
+		ConnConfig:     pgCfg.ConnConfig.Copy(),
+		OnlyPrint:      pgCfg.OnlyPrint,
+		IsolationLevel: pgCfg.IsolationLevel,
+		limiter:        pgCfg.limiter,
+	}
+}
+
+// Limits the number of connections opened at the same time.
+type connLimiter struct {
+	slots chan struct{}
+}
+
+func newConnLimiter(max int) *connLimiter {
+	return &connLimiter{
+		slots: make(chan struct{}, max),
 	}
 }
+
+func (limiter *connLimiter) acquire(ctx context.Context) error {
+	if limiter == nil {
+		return nil
+	}
+
+	select {
+	case limiter.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (limiter *connLimiter) available() bool {
+	return limiter == nil || len(limiter.slots) < cap(limiter.slots)
+}
+
+func (limiter *connLimiter) release() {
+	if limiter == nil {
+		return
+	}
+
+	<-limiter.slots
+}
@@ -0,0 +1,158 @@
+package rsslap
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	S3DefaultRegion = "us-east-1"
+)
+
+// Split "s3://bucket/key" into the bucket and the key.
+func ParseS3URL(s3URL string) (bucket string, key string, err error) {
+	if !strings.HasPrefix(s3URL, "s3://") {
+		return "", "", fmt.Errorf("invalid S3 URL (must start with 's3://'): %s", s3URL)
+	}
+
+	path := strings.TrimPrefix(s3URL, "s3://")
+	idx := strings.Index(path, "/")
+
+	if idx < 0 {
+		bucket = path
+	} else {
+		bucket, key = path[:idx], path[idx+1:]
+	}
+
+	if bucket == "" {
+		return "", "", fmt.Errorf("invalid S3 URL (bucket is empty): %s", s3URL)
+	}
+
+	return bucket, key, nil
+}
+
+// Upload an object with a request signed by AWS Signature Version 4,
+// using the credentials in AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+func putS3Object(ctx context.Context, s3URL string, body []byte) error {
+	bucket, key, err := ParseS3URL(s3URL)
+
+	if err != nil {
+		return err
+	}
+
+	region := os.Getenv("AWS_REGION")
+
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+
+	if region == "" {
+		region = S3DefaultRegion
+	}
+
+	host := fmt.Sprintf("%s.s3.%s.amazonaws.com", bucket, region)
+	uri := "/" + awsURIEncode(key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, "https://"+host+uri, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	headers := map[string]string{
+		"host":                 host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+
+	if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+		headers["x-amz-security-token"] = token
+	}
+
+	names := make([]string, 0, len(headers))
+
+	for name := range headers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	canonicalHeaders := strings.Builder{}
+
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+
+		if name != "host" {
+			req.Header.Set(name, headers[name])
+		}
+	}
+
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{http.MethodPut, uri, "", canonicalHeaders.String(), signedHeaders, payloadHash}, "\n")
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	signingKey := []byte("AWS4" + os.Getenv("AWS_SECRET_ACCESS_KEY"))
+
+	for _, v := range []string{date, region, "s3", "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, v)
+	}
+
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		os.Getenv("AWS_ACCESS_KEY_ID"), scope, signedHeaders, signature))
+
+	res, err := http.DefaultClient.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("S3 upload error (url=%s, status=%s): %s", s3URL, res.Status, msg)
+	}
+
+	return nil
+}
+
+func sha256Hex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// Encode the path as required by AWS, leaving only unreserved characters and '/'.
+func awsURIEncode(path string) string {
+	sb := strings.Builder{}
+
+	for _, b := range []byte(path) {
+		if b >= 'A' && b <= 'Z' || b >= 'a' && b <= 'z' || b >= '0' && b <= '9' || strings.IndexByte("-_.~/", b) >= 0 {
+			sb.WriteByte(b)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", b)
+		}
+	}
+
+	return sb.String()
+}
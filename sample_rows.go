@@ -0,0 +1,64 @@
+package rsslap
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Print rows generated as by the pre-population, without connecting to the DB.
+// NOTE: The values generated by the DB (identity and gen_random_uuid()) are emulated
+func PrintSampleRows(w io.Writer, dataOpts *DataOpts, n int) error {
+	data := newData(dataOpts, nil, dataOpts.Seed)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(dataOpts.GeneratedColumns(), "\t"))
+
+	for r := 1; r <= n; r++ {
+		_, args := data.buildInsertStmt()
+		row := []string{}
+
+		if data.PkType == PkTypeNumeric || data.PkType == PkTypeVarchar {
+			row = append(row, fmt.Sprint(args[0]))
+			args = args[1:]
+		} else if data.GuidPrimary {
+			row = append(row, randomUUID())
+		} else {
+			row = append(row, strconv.Itoa(r))
+		}
+
+		for i := 1; i <= data.NumberSecondaryIndexes; i++ {
+			row = append(row, randomUUID())
+		}
+
+		for _, v := range args {
+			if ts, ok := v.(time.Time); ok {
+				row = append(row, ts.Format(CopyTimestampLayout))
+			} else if v == nil {
+				row = append(row, "NULL")
+			} else {
+				row = append(row, fmt.Sprint(v))
+			}
+		}
+
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+func randomUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return formatUUID(b)
+}
+
+func formatUUID(b [16]byte) string {
+	// Version 4, variant 10
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,64 @@
+package rsslap
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// Streams latency samples to a Unix domain socket as "<unix timestamp ns>,<response time ns>" lines.
+type sampleStream struct {
+	conn net.Conn
+	w    *bufio.Writer
+	buf  []byte
+}
+
+func openSampleStream(path string) (*sampleStream, error) {
+	conn, err := net.Dial("unix", path)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to samples socket (path=%s): %w", path, err)
+	}
+
+	ss := &sampleStream{
+		conn: conn,
+		w:    bufio.NewWriter(conn),
+	}
+
+	return ss, nil
+}
+
+func (ss *sampleStream) write(recDps []recorderDataPoint) error {
+	for _, v := range recDps {
+		ss.buf = strconv.AppendInt(ss.buf[:0], v.timestamp.UnixNano(), 10)
+		ss.buf = append(ss.buf, ',')
+		ss.buf = strconv.AppendInt(ss.buf, int64(v.resTime), 10)
+		ss.buf = append(ss.buf, '\n')
+
+		if _, err := ss.w.Write(ss.buf); err != nil {
+			return err
+		}
+	}
+
+	return ss.w.Flush()
+}
+
+func (ss *sampleStream) close() {
+	ss.w.Flush()
+	ss.conn.Close()
+}
+
+// Stop streaming (instead of failing the test) when the listener goes away.
+func (rec *Recorder) writeSamples(recDps []recorderDataPoint) {
+	if rec.samples == nil {
+		return
+	}
+
+	if err := rec.samples.write(recDps); err != nil {
+		fmt.Fprintf(os.Stderr, "\n[WARN] Stop streaming samples: %s\n", err)
+		rec.samples.conn.Close()
+		rec.samples = nil
+	}
+}
@@ -0,0 +1,110 @@
+package rsslap
+
+import (
+	"context"
+	"time"
+)
+
+// Phase of '--schedule'. The phases run back to back with the same agents and connections.
+type SchedulePhase struct {
+	Name     string
+	Duration time.Duration
+	NAgents  int
+	Rate     float64
+	// Overrides of the data options, empty to keep them
+	LoadType      AutoGenerateSqlLoadType `json:",omitempty"`
+	Queries       []string                `json:"-"`
+	MixedSelRatio int                     `json:",omitempty"`
+	MixedInsRatio int                     `json:",omitempty"`
+}
+
+// Summary of a phase of '--schedule'.
+type PhaseReport struct {
+	Phase      int
+	Name       string
+	Agents     int
+	QueryCount int
+	ErrorCount int
+	QPS        float64
+	Avg        time.Duration
+	P50        time.Duration
+	P99        time.Duration
+}
+
+// Data options of the phase.
+func (phase *SchedulePhase) dataOpts(base *DataOpts) *DataOpts {
+	opts := *base
+
+	if phase.LoadType != "" {
+		opts.LoadType = phase.LoadType
+	}
+
+	if len(phase.Queries) > 0 {
+		opts.Queries = phase.Queries
+		opts.QueryWeights = nil
+		opts.QueryTags = nil
+	}
+
+	if phase.MixedSelRatio > 0 {
+		opts.MixedSelRatio = phase.MixedSelRatio
+		opts.MixedInsRatio = phase.MixedInsRatio
+	}
+
+	return &opts
+}
+
+// Run the loop for each phase until its end, and idle through the phases the agent does not take part in.
+// NOTE: Without '--schedule', the whole test is a single phase
+func (agent *Agent) runPhases(stopCtx context.Context, recorder *Recorder, loop func(phaseCtx context.Context, rate float64) error) error {
+	if len(agent.taskOps.Schedule) == 0 {
+		return loop(stopCtx, agent.taskOps.Rate)
+	}
+
+	phaseEnd := recorder.measureStart()
+
+	for k, phase := range agent.taskOps.Schedule {
+		phaseEnd = phaseEnd.Add(phase.Duration)
+		phaseCtx, cancel := context.WithDeadline(stopCtx, phaseEnd)
+		var err error
+
+		if agent.id < phase.NAgents {
+			agent.data = agent.phaseData[k]
+			err = loop(phaseCtx, phase.Rate)
+		}
+
+		<-phaseCtx.Done()
+		cancel()
+
+		if err != nil || stopCtx.Err() != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (rec *Recorder) phaseReports() []PhaseReport {
+	durations := make([]time.Duration, len(rec.Schedule))
+
+	for i, phase := range rec.Schedule {
+		durations[i] = phase.Duration
+	}
+
+	reports := []PhaseReport{}
+
+	for k, ps := range rec.periodStats(durations) {
+		reports = append(reports, PhaseReport{
+			Phase:      k + 1,
+			Name:       rec.Schedule[k].Name,
+			Agents:     rec.Schedule[k].NAgents,
+			QueryCount: ps.queryCount,
+			ErrorCount: ps.errorCount,
+			QPS:        ps.qps,
+			Avg:        ps.avg,
+			P50:        ps.p50,
+			P99:        ps.p99,
+		})
+	}
+
+	return reports
+}
@@ -0,0 +1,56 @@
+package rsslap
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Result of '--sla-p99' and '--sla-error-rate', e.g. to fail the CI build on a latency regression.
+type SLAReport struct {
+	P99        time.Duration `json:",omitempty"`
+	ErrorRate  float64       `json:",omitempty"`
+	Violations []string
+}
+
+func (r *SLAReport) Passed() bool {
+	return len(r.Violations) == 0
+}
+
+func (r *SLAReport) String() string {
+	if r.Passed() {
+		return "passed"
+	}
+
+	return "FAILED (" + strings.Join(r.Violations, ", ") + ")"
+}
+
+// Compare the results with the thresholds, or return nil without them.
+// NOTE: The error rate is the ratio of the failed queries to all the queries
+func (rec *Recorder) slaReport(rr *RecorderReport) *SLAReport {
+	if rec.SLAP99 <= 0 && rec.SLAErrorRate <= 0 {
+		return nil
+	}
+
+	r := &SLAReport{
+		P99:        rec.SLAP99,
+		ErrorRate:  rec.SLAErrorRate,
+		Violations: []string{},
+	}
+
+	if rec.SLAP99 > 0 {
+		if rr.Response.Samples == 0 {
+			r.Violations = append(r.Violations, "p99: no successful queries")
+		} else if rr.Response.P99 > rec.SLAP99 {
+			r.Violations = append(r.Violations, fmt.Sprintf("p99 %s > %s", rr.Response.P99, rec.SLAP99))
+		}
+	}
+
+	if total := rr.QueryCount + rr.ErrorCount; rec.SLAErrorRate > 0 && total > 0 {
+		if errRate := float64(rr.ErrorCount) / float64(total); errRate > rec.SLAErrorRate {
+			r.Violations = append(r.Violations, fmt.Sprintf("error rate %.4f > %g", errRate, rec.SLAErrorRate))
+		}
+	}
+
+	return r
+}
@@ -0,0 +1,315 @@
+package rsslap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/winebarrel/randstr"
+)
+
+const (
+	SQLTemplateDateLayout      = "2006-01-02"
+	SQLTemplateTimestampLayout = "2006-01-02 15:04:05"
+	// Estimated length of an expanded placeholder, to allocate the query at once
+	SQLTemplatePlaceholderSize = 16
+)
+
+// Query of '--sql-template', split into the literal parts and the placeholders, e.g. "{{randint 1 1000}}".
+type sqlTemplate struct {
+	parts []sqlTemplatePart
+	// Length of the literal parts
+	size int
+}
+
+type sqlTemplatePart struct {
+	literal string
+	fn      *sqlTemplateFunc
+	args    []string
+}
+
+type sqlTemplateFunc struct {
+	name    string
+	minArgs int
+	// Negative if variadic
+	maxArgs int
+	check   func(args []string) error
+	expand  func(data *Data, args []string) string
+}
+
+var sqlTemplateFuncs = map[string]*sqlTemplateFunc{}
+
+func init() {
+	for _, fn := range []*sqlTemplateFunc{
+		{
+			// {{randint MIN MAX}}: integer between MIN and MAX
+			name: "randint", minArgs: 2, maxArgs: 2,
+			check: checkTemplateInts,
+			expand: func(data *Data, args []string) string {
+				min, max := templateInt(args[0]), templateInt(args[1])
+				return strconv.FormatInt(min+data.randSrc.Int63()%(max-min+1), 10)
+			},
+		},
+		{
+			// {{randintlist N MIN MAX}}: comma-separated N integers between MIN and MAX, e.g. for 'IN (...)'
+			name: "randintlist", minArgs: 3, maxArgs: 3,
+			check: checkTemplateInts,
+			expand: func(data *Data, args []string) string {
+				n, min, max := templateInt(args[0]), templateInt(args[1]), templateInt(args[2])
+				ints := make([]string, n)
+
+				for i := range ints {
+					ints[i] = strconv.FormatInt(min+data.randSrc.Int63()%(max-min+1), 10)
+				}
+
+				return strings.Join(ints, ",")
+			},
+		},
+		{
+			// {{randstr N}}: alphanumeric string of N characters
+			name: "randstr", minArgs: 1, maxArgs: 1,
+			check: checkTemplateInts,
+			expand: func(data *Data, args []string) string {
+				return randstr.String(data.randSrc, int(templateInt(args[0])))
+			},
+		},
+		{
+			// {{randchoice A B ...}}: one of the arguments
+			name: "randchoice", minArgs: 1, maxArgs: -1,
+			expand: func(data *Data, args []string) string {
+				return args[data.randSrc.Int63()%int64(len(args))]
+			},
+		},
+		{
+			// {{date OFFSET}}: date relative to now, e.g. "{{date -7d}}"
+			name: "date", minArgs: 1, maxArgs: 1,
+			check: checkTemplateOffsets,
+			expand: func(data *Data, args []string) string {
+				return time.Now().Add(templateOffset(args[0])).Format(SQLTemplateDateLayout)
+			},
+		},
+		{
+			// {{timestamp OFFSET}}: timestamp relative to now, e.g. "{{timestamp -1h}}"
+			name: "timestamp", minArgs: 1, maxArgs: 1,
+			check: checkTemplateOffsets,
+			expand: func(data *Data, args []string) string {
+				return time.Now().Add(templateOffset(args[0])).Format(SQLTemplateTimestampLayout)
+			},
+		},
+		{
+			// {{now}}: current timestamp
+			name: "now",
+			expand: func(data *Data, args []string) string {
+				return time.Now().Format(SQLTemplateTimestampLayout)
+			},
+		},
+		{
+			// {{uuid}}: random UUID
+			name: "uuid",
+			expand: func(data *Data, args []string) string {
+				return data.randUUID()
+			},
+		},
+		{
+			// {{agentid}}: ID of the agent, e.g. to give each agent its own rows
+			name: "agentid",
+			expand: func(data *Data, args []string) string {
+				return strconv.Itoa(data.agentId)
+			},
+		},
+		{
+			// {{seq}}: counter of the agent that increases with each expansion, starting from 1
+			name: "seq",
+			expand: func(data *Data, args []string) string {
+				data.templateSeq++
+				return strconv.FormatInt(data.templateSeq, 10)
+			},
+		},
+		{
+			// {{col NAME}}: value of the column of a row of '--param-file', the same row within a query
+			name: "col", minArgs: 1, maxArgs: 1,
+			expand: func(data *Data, args []string) string {
+				return data.paramValue(args[0])
+			},
+		},
+		{
+			// {{randdate FROM TO}}: date between the offsets from now, e.g. "{{randdate -30d 0d}}"
+			name: "randdate", minArgs: 2, maxArgs: 2,
+			check: checkTemplateOffsets,
+			expand: func(data *Data, args []string) string {
+				from, to := templateOffset(args[0]), templateOffset(args[1])
+				offset := from + time.Duration(data.randSrc.Int63()%(int64(to-from)+1))
+				return time.Now().Add(offset).Format(SQLTemplateDateLayout)
+			},
+		},
+	} {
+		sqlTemplateFuncs[fn.name] = fn
+	}
+}
+
+func parseSQLTemplate(query string) (*sqlTemplate, error) {
+	tmpl := &sqlTemplate{}
+	rest := query
+
+	for {
+		start := strings.Index(rest, "{{")
+
+		if start < 0 {
+			break
+		}
+
+		end := strings.Index(rest[start:], "}}")
+
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed placeholder: %s", rest[start:])
+		}
+
+		placeholder := rest[start+2 : start+end]
+		fields := strings.Fields(placeholder)
+
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("empty placeholder in template: %s", query)
+		}
+
+		fn, ok := sqlTemplateFuncs[fields[0]]
+
+		if !ok {
+			return nil, fmt.Errorf("unknown template function: %s", fields[0])
+		}
+
+		args := fields[1:]
+
+		for i, v := range args {
+			args[i] = strings.Trim(v, `"'`)
+		}
+
+		if len(args) < fn.minArgs || fn.maxArgs >= 0 && len(args) > fn.maxArgs {
+			return nil, fmt.Errorf("wrong number of arguments: {{%s}}", placeholder)
+		}
+
+		if fn.check != nil {
+			if err := fn.check(args); err != nil {
+				return nil, fmt.Errorf("%w: {{%s}}", err, placeholder)
+			}
+		}
+
+		tmpl.parts = append(tmpl.parts, sqlTemplatePart{literal: rest[:start]}, sqlTemplatePart{fn: fn, args: args})
+		tmpl.size += start
+		rest = rest[start+end+2:]
+	}
+
+	tmpl.parts = append(tmpl.parts, sqlTemplatePart{literal: rest})
+	tmpl.size += len(rest)
+
+	return tmpl, nil
+}
+
+// Check the placeholders of the queries of '--sql-template', and the columns of '{{col NAME}}' in the params.
+func ValidateSQLTemplates(queries []string, params *ParamTable) error {
+	for _, q := range queries {
+		tmpl, err := parseSQLTemplate(q)
+
+		if err != nil {
+			return err
+		}
+
+		for _, p := range tmpl.parts {
+			if p.fn == nil || p.fn.name != "col" {
+				continue
+			}
+
+			if params == nil {
+				return fmt.Errorf("'--param-file' is required: {{col %s}}", p.args[0])
+			} else if _, ok := params.colIdx[p.args[0]]; !ok {
+				return fmt.Errorf("unknown column of '--param-file': {{col %s}}", p.args[0])
+			}
+		}
+	}
+
+	return nil
+}
+
+func (tmpl *sqlTemplate) expand(data *Data) string {
+	sb := strings.Builder{}
+	sb.Grow(tmpl.size + SQLTemplatePlaceholderSize*(len(tmpl.parts)/2))
+	data.paramRow = nil
+
+	for _, p := range tmpl.parts {
+		if p.fn != nil {
+			sb.WriteString(p.fn.expand(data, p.args))
+		} else {
+			sb.WriteString(p.literal)
+		}
+	}
+
+	return sb.String()
+}
+
+func checkTemplateInts(args []string) error {
+	ints := make([]int64, len(args))
+
+	for i, v := range args {
+		n, err := strconv.ParseInt(v, 10, 64)
+
+		if err != nil {
+			return fmt.Errorf("invalid integer '%s'", v)
+		}
+
+		ints[i] = n
+	}
+
+	// NOTE: MIN and MAX are the last two arguments
+	if len(ints) >= 2 && ints[len(ints)-2] > ints[len(ints)-1] {
+		return fmt.Errorf("MIN must be <= MAX")
+	} else if len(ints) != 2 && ints[0] < 0 {
+		return fmt.Errorf("length must be >= 0")
+	}
+
+	return nil
+}
+
+func checkTemplateOffsets(args []string) error {
+	for _, v := range args {
+		if _, err := parseTemplateOffset(v); err != nil {
+			return err
+		}
+	}
+
+	if len(args) == 2 && templateOffset(args[0]) > templateOffset(args[1]) {
+		return fmt.Errorf("FROM must be <= TO")
+	}
+
+	return nil
+}
+
+func templateInt(s string) int64 {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
+
+func templateOffset(s string) time.Duration {
+	d, _ := parseTemplateOffset(s)
+	return d
+}
+
+// Parse a duration that also accepts days, e.g. "-7d" or "-1h30m".
+func parseTemplateOffset(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+
+		if err != nil {
+			return 0, fmt.Errorf("invalid offset '%s'", s)
+		}
+
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(s)
+
+	if err != nil {
+		return 0, fmt.Errorf("invalid offset '%s'", s)
+	}
+
+	return d, nil
+}
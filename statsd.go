@@ -0,0 +1,139 @@
+package rsslap
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultStatsdPrefix = "rsslap"
+	// Keep the packets below the typical MTU
+	StatsdMaxPacketSize = 1432
+	// Timings sent per batch of data points; the rest are represented by the sample rate
+	StatsdMaxTimingsPerBatch = 100
+)
+
+// Emits the samples of the recorder to StatsD over UDP.
+// NOTE: Errors are reported once and never abort the test
+type statsdEmitter struct {
+	conn   net.Conn
+	prefix string
+	buf    []byte
+	warned bool
+}
+
+func openStatsdEmitter(addr string, prefix string) *statsdEmitter {
+	conn, err := net.Dial("udp", addr)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[WARN] Metrics are not sent to StatsD (addr=%s): %s\n", addr, err)
+		return nil
+	}
+
+	if prefix != "" && !strings.HasSuffix(prefix, ".") {
+		prefix += "."
+	}
+
+	return &statsdEmitter{
+		conn:   conn,
+		prefix: prefix,
+		buf:    make([]byte, 0, StatsdMaxPacketSize),
+	}
+}
+
+func (se *statsdEmitter) emit(recDps []recorderDataPoint) {
+	if len(recDps) == 0 {
+		return
+	}
+
+	queryCnt := 0
+	errCnt := 0
+	timeoutCnt := 0
+
+	for _, v := range recDps {
+		if v.failed {
+			errCnt++
+
+			if v.timedOut {
+				timeoutCnt++
+			}
+		} else {
+			queryCnt++
+		}
+	}
+
+	se.write(fmt.Sprintf("%squeries:%d|c", se.prefix, queryCnt))
+
+	if errCnt > 0 {
+		se.write(fmt.Sprintf("%serrors:%d|c", se.prefix, errCnt))
+	}
+
+	if timeoutCnt > 0 {
+		se.write(fmt.Sprintf("%stimeout_errors:%d|c", se.prefix, timeoutCnt))
+	}
+
+	// Send evenly spaced timings of the successful queries with the sample rate
+	step := 1
+
+	if queryCnt > StatsdMaxTimingsPerBatch {
+		step = (queryCnt + StatsdMaxTimingsPerBatch - 1) / StatsdMaxTimingsPerBatch
+	}
+
+	rate := ""
+
+	if step > 1 {
+		rate = "|@" + strconv.FormatFloat(1/float64(step), 'g', 6, 64)
+	}
+
+	i := 0
+
+	for _, v := range recDps {
+		if v.failed {
+			continue
+		}
+
+		if i%step == 0 {
+			ms := strconv.FormatFloat(float64(v.resTime.Microseconds())/1000, 'f', 3, 64)
+			se.write(se.prefix + "latency:" + ms + "|ms" + rate)
+		}
+
+		i++
+	}
+
+	se.flush()
+}
+
+// Append a metric to the packet, sending the packet when it is full.
+func (se *statsdEmitter) write(metric string) {
+	if len(se.buf) > 0 && len(se.buf)+1+len(metric) > StatsdMaxPacketSize {
+		se.flush()
+	}
+
+	if len(se.buf) > 0 {
+		se.buf = append(se.buf, '\n')
+	}
+
+	se.buf = append(se.buf, metric...)
+}
+
+func (se *statsdEmitter) flush() {
+	if len(se.buf) == 0 {
+		return
+	}
+
+	_, err := se.conn.Write(se.buf)
+	se.buf = se.buf[:0]
+
+	if err != nil && !se.warned {
+		fmt.Fprintf(os.Stderr, "\n[WARN] Failed to send metrics to StatsD: %s\n", err)
+		se.warned = true
+	}
+}
+
+func (se *statsdEmitter) close() {
+	se.flush()
+	se.conn.Close()
+}
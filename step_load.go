@@ -0,0 +1,145 @@
+package rsslap
+
+import (
+	"sort"
+	"time"
+)
+
+// Summary of a step of '--step-agents', to find where the latency collapses as the agents increase.
+type StepReport struct {
+	Step       int
+	Agents     int
+	QueryCount int
+	ErrorCount int
+	QPS        float64
+	Avg        time.Duration
+	P50        time.Duration
+	P99        time.Duration
+}
+
+// Number of the steps to reach StepMax agents.
+func (taskOpts *TaskOpts) numSteps() int {
+	return (taskOpts.StepMax + taskOpts.StepAgents - 1) / taskOpts.StepAgents
+}
+
+// Number of the agents running at the step (zero-based).
+func (taskOpts *TaskOpts) stepAgentCount(step int) int {
+	n := taskOpts.StepAgents * (step + 1)
+
+	if n > taskOpts.StepMax {
+		n = taskOpts.StepMax
+	}
+
+	return n
+}
+
+// Delay before the agent starts: the agents of step k start at k * StepDuration.
+func (taskOpts *TaskOpts) stepStartDelay(agentId int) time.Duration {
+	return taskOpts.StepDuration * time.Duration(agentId/taskOpts.StepAgents)
+}
+
+func (rec *Recorder) stepReports() []StepReport {
+	durations := make([]time.Duration, rec.numSteps())
+
+	for i := range durations {
+		durations[i] = rec.StepDuration
+	}
+
+	reports := []StepReport{}
+
+	for step, ps := range rec.periodStats(durations) {
+		reports = append(reports, StepReport{
+			Step:       step + 1,
+			Agents:     rec.stepAgentCount(step),
+			QueryCount: ps.queryCount,
+			ErrorCount: ps.errorCount,
+			QPS:        ps.qps,
+			Avg:        ps.avg,
+			P50:        ps.p50,
+			P99:        ps.p99,
+		})
+	}
+
+	return reports
+}
+
+type periodStats struct {
+	queryCount int
+	errorCount int
+	qps        float64
+	avg        time.Duration
+	p50        time.Duration
+	p99        time.Duration
+}
+
+// Statistics of the consecutive periods of the durations from the start of the measurement.
+// NOTE: The samples after the last period are counted in it, and the periods after the end of the test are omitted
+func (rec *Recorder) periodStats(durations []time.Duration) []periodStats {
+	ends := make([]time.Time, len(durations))
+	end := rec.startedAt
+
+	for i, d := range durations {
+		end = end.Add(d)
+		ends[i] = end
+	}
+
+	periodOf := func(v recorderDataPoint) int {
+		return sort.Search(len(ends)-1, func(i int) bool { return v.timestamp.Before(ends[i]) })
+	}
+
+	resTimes := make([][]time.Duration, len(durations))
+	errCnts := make([]int, len(durations))
+
+	for _, v := range rec.dataPoints {
+		p := periodOf(v)
+		resTimes[p] = append(resTimes[p], v.resTime)
+	}
+
+	for _, v := range rec.errorDataPoints {
+		errCnts[periodOf(v)]++
+	}
+
+	stats := []periodStats{}
+	periodStart := rec.startedAt
+
+	for p, d := range durations {
+		// NOTE: The test stopped before the period
+		if !periodStart.Before(rec.finishedAt) {
+			break
+		}
+
+		elapsed := d
+
+		if periodEnd := periodStart.Add(elapsed); periodEnd.After(rec.finishedAt) {
+			elapsed = rec.finishedAt.Sub(periodStart)
+		}
+
+		times := resTimes[p]
+		sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+		ps := periodStats{
+			queryCount: len(times),
+			errorCount: errCnts[p],
+			p50:        percentile(times, 50),
+			p99:        percentile(times, 99),
+		}
+
+		if elapsed > 0 {
+			ps.qps = float64(len(times)) / elapsed.Seconds()
+		}
+
+		if len(times) > 0 {
+			var total time.Duration
+
+			for _, t := range times {
+				total += t
+			}
+
+			ps.avg = total / time.Duration(len(times))
+		}
+
+		stats = append(stats, ps)
+		periodStart = periodStart.Add(d)
+	}
+
+	return stats
+}
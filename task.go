@@ -7,51 +7,148 @@ import (
 	"math/rand"
 	"os"
 	"os/signal"
+	"runtime"
+	"strings"
 	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/jackc/pgx/v4"
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/term"
 )
 
+type ResultMemAction string
+type ArrivalModel string
+type DelayDistribution string
+
 const (
-	ProgressReportPeriod = 1
+	ProgressReportPeriod  = 1
+	MemCheckPeriod        = 1 * time.Second
+	ResultMemActionStream = ResultMemAction("stream")
+	ResultMemActionError  = ResultMemAction("error")
+	ArrivalUniform        = ArrivalModel("uniform")
+	ArrivalPoisson        = ArrivalModel("poisson")
+	DelayDistUniform      = DelayDistribution("uniform")
+	DelayDistNormal       = DelayDistribution("normal")
+	DelayDistExp          = DelayDistribution("exp")
+	// Added to the seed, not to share the source of '--start-spread' with the data of any agent
+	StartSpreadSeedOffset = 3 << 32
 )
 
 type TaskOpts struct {
-	RsConfig               *RsConfig `json:"-"`
-	NAgents                int
-	Time                   time.Duration `json:"-"`
-	Rate                   int
-	Delay                  int
-	Spread                 int
-	AutoGenerateSql        bool
-	NumberPrePopulatedData int
-	NumberQueriesToExecute int
-	DropExistingDatabase   bool
-	UseExistingDatabase    bool
-	NoDropDatabase         bool
-	Creates                []string `json:"-"`
-	OnlyPrint              bool     `json:"-"`
-	NoProgress             bool     `json:"-"`
+	RsConfig                *RsConfig `json:"-"`
+	NAgents                 int
+	Time                    time.Duration `json:"-"`
+	Warmup                  time.Duration
+	WarmupQueries           int
+	RampUp                  time.Duration
+	IncludeRampUp           bool
+	Rate                    float64
+	Delay                   int
+	Spread                  int
+	DelayDistribution       DelayDistribution
+	StartSpread             time.Duration
+	StatementInterval       time.Duration
+	StatementIntervalSpread time.Duration
+	GlobalRate              float64
+	Arrival                 ArrivalModel
+	StepAgents              int
+	StepDuration            time.Duration
+	StepMax                 int
+	Schedule                []SchedulePhase `json:",omitempty"`
+	Drain                   bool
+	DrainTimeout            time.Duration
+	QueryTimeout            time.Duration
+	AutoGenerateSql         bool
+	NumberPrePopulatedData  int
+	NumberQueriesToExecute  int
+	MaxBytesWritten         uint64
+	DropExistingDatabase    bool
+	UseExistingDatabase     bool
+	NoDropDatabase          bool
+	DBPerAgent              bool
+	ClientMemLimit          uint64
+	MaxErrorRate            float64
+	ChecksumResults         bool
+	MaxConnectionsTotal     int
+	PhaseCheckQuery         string
+	PhaseCheckExpect        string
+	AbandonRate             float64
+	AgentResultMemLimit     uint64
+	AgentResultMemAction    ResultMemAction
+	DisableStatementCache   bool
+	Prepared                bool
+	ContinueOnError         bool
+	ContinueOnPreQueryError bool
+	PoolSize                int
+	MaxRetries              int
+	RetryBackoff            time.Duration
+	DiscardResults          bool
+	Probe                   bool
+	CPUProfile              string   `json:"-"`
+	MemProfile              string   `json:"-"`
+	Creates                 []string `json:"-"`
+	OnlyPrint               bool     `json:"-"`
+	NoProgress              bool     `json:"-"`
+	Dashboard               bool     `json:"-"`
+	LiveHistogram           bool     `json:"-"`
 }
 
-type Task struct {
-	*TaskOpts
-	agents   []*Agent
-	dataOpts *DataOpts
-	recOpts  *RecorderOpts
+// Duration from the start whose samples are excluded from the report: the ramp-up, then the warm-up.
+func (taskOpts *TaskOpts) unmeasured() time.Duration {
+	if taskOpts.IncludeRampUp {
+		return taskOpts.Warmup
+	}
+
+	return taskOpts.RampUp + taskOpts.Warmup
+}
+
+// Delay before the agent starts, so that the agents running at the beginning start evenly over the ramp-up.
+// NOTE: Agent 0 starts at once and the last one at the end of the ramp-up. With '--schedule', the agents
+// of the first phase ramp up and the others wait for the end of it.
+func (taskOpts *TaskOpts) rampUpStartDelay(agentId int) time.Duration {
+	n := taskOpts.NAgents
+
+	if len(taskOpts.Schedule) > 0 {
+		n = taskOpts.Schedule[0].NAgents
+	}
+
+	if agentId >= n {
+		return taskOpts.RampUp
+	} else if n <= 1 {
+		return 0
+	}
+
+	return taskOpts.RampUp * time.Duration(agentId) / time.Duration(n-1)
 }
 
-func init() {
-	rand.Seed(time.Now().UnixNano())
+type Task struct {
+	*TaskOpts
+	agents    []*Agent
+	dataOpts  *DataOpts
+	recOpts   *RecorderOpts
+	checkConn DB
+	pool      *connPool
 }
 
 func NewTask(taskOpts *TaskOpts, dataOpts *DataOpts, recOpts *RecorderOpts) (task *Task) {
+	if taskOpts.MaxConnectionsTotal > 0 {
+		taskOpts.RsConfig.limiter = newConnLimiter(taskOpts.MaxConnectionsTotal)
+	}
+
 	agents := make([]*Agent, taskOpts.NAgents)
 
 	for i := 0; i < taskOpts.NAgents; i++ {
-		agents[i] = newAgent(i, taskOpts.RsConfig, taskOpts, dataOpts)
+		rsConfig := taskOpts.RsConfig
+
+		// NOTE: e.g. "rsslap_0", "rsslap_1", ... from the database of the URL
+		if taskOpts.DBPerAgent {
+			rsConfig = taskOpts.RsConfig.Copy()
+			rsConfig.Database = fmt.Sprintf("%s_%d", taskOpts.RsConfig.Database, i)
+		}
+
+		agents[i] = newAgent(i, rsConfig, taskOpts, dataOpts)
 	}
 
 	task = &Task{
@@ -65,40 +162,106 @@ func NewTask(taskOpts *TaskOpts, dataOpts *DataOpts, recOpts *RecorderOpts) (tas
 }
 
 func (task *Task) Prepare() error {
-	idList, err := task.setupDB()
+	idLists, err := task.setupDB()
 
 	if err != nil {
 		return fmt.Errorf("failed to setup DB: %w", err)
 	}
 
+	// NOTE: Open the connection for the phase check before the agents,
+	// so that it is not blocked by '--max-connections-total'
+	if task.PhaseCheckQuery != "" {
+		task.checkConn, err = task.RsConfig.openAndPing(context.Background())
+
+		if err != nil {
+			return fmt.Errorf("failed to open/ping DB for phase check: %w", err)
+		}
+	}
+
+	if task.PoolSize > 0 {
+		task.pool, err = newConnPool(context.Background(), task.RsConfig, task.PoolSize, task.dataOpts.PreQueries, task.ContinueOnPreQueryError)
+
+		if err != nil {
+			return fmt.Errorf("failed to open connection pool: %w", err)
+		}
+	}
+
+	var globalLimiter *globalRateLimiter
+
+	if task.GlobalRate > 0 {
+		globalLimiter = newGlobalRateLimiter(task.GlobalRate)
+	}
+
 	for _, agent := range task.agents {
+		agent.pool = task.pool
+		agent.globalLimiter = globalLimiter
+		var idList []string
+
+		if task.DBPerAgent && idLists != nil {
+			idList = idLists[agent.id]
+		} else if idLists != nil {
+			idList = idLists[0]
+		}
+
 		if err := agent.prepare(idList); err != nil {
 			return fmt.Errorf("failed to prepare Agent: %w", err)
 		}
 	}
 
+	if deferred := task.deferredAgentCount(); deferred > 0 {
+		fmt.Fprintf(os.Stderr, "[WARN] '--max-connections-total' (%d) constrains concurrency: %d of %d agents wait for a free connection\n",
+			task.MaxConnectionsTotal, deferred, task.NAgents)
+	}
+
 	return nil
 }
 
-func (task *Task) createDatabase() error {
+func (task *Task) deferredAgentCount() int {
+	cnt := 0
+
+	for _, agent := range task.agents {
+		if agent.deferred {
+			cnt++
+		}
+	}
+
+	return cnt
+}
+
+// Configs of the databases that the agents run against, which are the same one without '--db-per-agent'.
+func (task *Task) databases() []*RsConfig {
+	if !task.DBPerAgent {
+		return []*RsConfig{task.RsConfig}
+	}
+
+	cfgs := make([]*RsConfig, len(task.agents))
+
+	for i, agent := range task.agents {
+		cfgs[i] = agent.rsConfig
+	}
+
+	return cfgs
+}
+
+func (task *Task) createDatabase(database string) error {
 	newCfg := task.RsConfig.Copy()
-	conn, err := newCfg.openAndPing()
+	conn, err := newCfg.openAndPing(context.Background())
 
 	if err != nil {
 		return fmt.Errorf("connection error: %w", err)
 	}
 
-	defer conn.Close(context.Background())
+	defer newCfg.closeConn(conn)
 
 	if task.DropExistingDatabase {
-		_, err = conn.Exec(context.Background(), fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", task.RsConfig.Database))
+		_, err = conn.Exec(context.Background(), fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", database))
 
 		if err != nil {
 			return fmt.Errorf("drop database error: %w", err)
 		}
 	}
 
-	row := conn.QueryRow(context.Background(), "SELECT COUNT(*) FROM pg_database WHERE datname = $1", task.RsConfig.Database)
+	row := conn.QueryRow(context.Background(), "SELECT COUNT(*) FROM pg_database WHERE datname = $1", database)
 	var dbCnt int
 
 	if _, ok := conn.(*NullDB); ok {
@@ -113,115 +276,257 @@ func (task *Task) createDatabase() error {
 	}
 
 	if dbCnt < 1 {
-		_, err = conn.Exec(context.Background(), fmt.Sprintf(`CREATE DATABASE "%s"`, task.RsConfig.Database))
+		_, err = conn.Exec(context.Background(), fmt.Sprintf(`CREATE DATABASE "%s"`, database))
 
 		if err != nil {
-			return fmt.Errorf("create database error: %w", err)
+			return fmt.Errorf("create database error (database=%s): %w", database, err)
 		}
 	} else {
+		// NOTE: With '--db-per-agent', no database is dropped if any of them exists
 		task.UseExistingDatabase = true
 	}
 
 	return nil
 }
 
-func (task *Task) setupDB() ([]string, error) {
+// Return the IDs of the pre-populated rows of each database.
+func (task *Task) setupDB() ([][]string, error) {
 	if task.AutoGenerateSql {
-		err := task.createDatabase()
+		for _, cfg := range task.databases() {
+			err := task.createDatabase(cfg.Database)
 
-		if err != nil {
-			return nil, err
+			if err != nil {
+				return nil, err
+			}
+
+			if len(task.Creates) > 0 {
+				err = task.createCustomTables(cfg)
+			} else {
+				err = task.createTable(cfg)
+			}
+
+			if err != nil {
+				return nil, err
+			}
 		}
 
-		conn, err := task.RsConfig.openAndPing()
+		if len(task.Creates) > 0 {
+			return make([][]string, len(task.databases())), nil
+		}
+
+		// NOTE: Do not hold a connection while pre-populating,
+		// so that the pre-populating agents can use all connections allowed by '--max-connections-total'
+		ctxWithoutCancel := context.Background()
+		ctx, cancel := context.WithCancel(ctxWithoutCancel)
+		eg := task.prePopulateData(ctx)
+		task.trapSigint(ctx, cancel, eg)
+		err := eg.Wait()
+		cancel()
 
 		if err != nil {
-			return nil, fmt.Errorf("connection error: %w", err)
+			return nil, fmt.Errorf("pre-populate data error: %w", err)
 		}
 
-		defer conn.Close(context.Background())
+		idLists := [][]string{}
 
-		if len(task.Creates) > 0 {
-			for _, stmt := range task.Creates {
-				_, err = conn.Exec(context.Background(), stmt)
+		for _, cfg := range task.databases() {
+			idList, err := task.fetchIdList(cfg)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if task.usesJoinTable() {
+				err = task.populateJoinTable(context.Background(), cfg, idList)
 
 				if err != nil {
-					return nil, fmt.Errorf("create table error (query=%s): %w", stmt, err)
+					return nil, fmt.Errorf("populate join table error: %w", err)
 				}
 			}
 
-			return []string{}, nil
+			idLists = append(idLists, idList)
 		}
 
-		_, err = conn.Exec(context.Background(), "DROP TABLE IF EXISTS "+AutoGenerateTableName)
+		return idLists, nil
+	}
+	return nil, nil
+}
+
+func (task *Task) createCustomTables(cfg *RsConfig) error {
+	conn, err := cfg.openAndPing(context.Background())
+
+	if err != nil {
+		return fmt.Errorf("connection error: %w", err)
+	}
+
+	defer cfg.closeConn(conn)
+
+	for _, stmt := range task.Creates {
+		_, err = conn.Exec(context.Background(), stmt)
 
 		if err != nil {
-			return nil, fmt.Errorf("drop table error: %w", err)
+			return fmt.Errorf("create table error (query=%s): %w", stmt, err)
 		}
+	}
+
+	return nil
+}
+
+func (task *Task) createTable(cfg *RsConfig) error {
+	conn, err := cfg.openAndPing(context.Background())
+
+	if err != nil {
+		return fmt.Errorf("connection error: %w", err)
+	}
 
-		tblStmt, idxStmts := newData(task.dataOpts, nil).buildCreateTableStmt()
-		_, err = conn.Exec(context.Background(), tblStmt)
+	defer cfg.closeConn(conn)
+
+	// NOTE: The join table references the auto-generated table
+	if task.usesJoinTable() {
+		_, err = conn.Exec(context.Background(), "DROP TABLE IF EXISTS "+JoinTableName)
 
 		if err != nil {
-			return nil, fmt.Errorf("create table error (query=%s): %w", tblStmt, err)
+			return fmt.Errorf("drop join table error: %w", err)
 		}
+	}
 
-		for _, idxStmt := range idxStmts {
-			_, err = conn.Exec(context.Background(), idxStmt)
+	_, err = conn.Exec(context.Background(), "DROP TABLE IF EXISTS "+AutoGenerateTableName)
 
-			if err != nil {
-				return nil, fmt.Errorf("create index error (query=%s): %w", idxStmt, err)
-			}
-		}
+	if err != nil {
+		return fmt.Errorf("drop table error: %w", err)
+	}
 
-		ctxWithoutCancel := context.Background()
-		ctx, cancel := context.WithCancel(ctxWithoutCancel)
-		eg := task.prePopulateData(ctx)
-		task.trapSigint(ctx, cancel, eg)
-		err = eg.Wait()
-		cancel()
+	data := newData(task.dataOpts, nil, task.dataOpts.Seed)
+
+	if data.PartitionBy != "" {
+		err = checkPartitioningSupport(conn)
 
 		if err != nil {
-			return nil, fmt.Errorf("pre-populate data error: %w", err)
+			return err
 		}
+	}
 
-		idList := make([]string, task.NumberPrePopulatedData*task.NAgents)
-		rs, err := conn.Query(context.Background(), "SELECT id::text FROM t1")
+	tblStmt, idxStmts := data.buildCreateTableStmt()
+	_, err = conn.Exec(context.Background(), tblStmt)
 
-		if _, ok := conn.(*NullDB); ok {
-			return idList, nil
-		}
+	if err != nil {
+		return fmt.Errorf("create table error (query=%s): %w", tblStmt, err)
+	}
+
+	for _, partStmt := range data.buildCreatePartitionStmts() {
+		_, err = conn.Exec(context.Background(), partStmt)
 
 		if err != nil {
-			return nil, fmt.Errorf("fetch id error: %w", err)
+			return fmt.Errorf("create partition error (query=%s): %w", partStmt, err)
 		}
+	}
 
-		for i := 0; rs.Next(); i++ {
-			err = rs.Scan(&idList[i])
+	for _, idxStmt := range idxStmts {
+		_, err = conn.Exec(context.Background(), idxStmt)
 
-			if err != nil {
-				return nil, fmt.Errorf("scan id error: %w", err)
-			}
+		if err != nil {
+			return fmt.Errorf("create index error (query=%s): %w", idxStmt, err)
 		}
+	}
+
+	if task.usesJoinTable() {
+		return task.createJoinTable(conn, data)
+	}
+
+	return nil
+}
+
+func (task *Task) fetchIdList(cfg *RsConfig) ([]string, error) {
+	conn, err := cfg.openAndPing(context.Background())
+
+	if err != nil {
+		return nil, fmt.Errorf("connection error: %w", err)
+	}
+
+	defer cfg.closeConn(conn)
+	numRows := task.NumberPrePopulatedData
+
+	if !task.DBPerAgent {
+		numRows *= task.NAgents
+	}
+
+	idList := make([]string, numRows)
+	rs, err := conn.Query(context.Background(), "SELECT id::text FROM "+AutoGenerateTableName)
 
+	if _, ok := conn.(*NullDB); ok {
 		return idList, nil
 	}
-	return nil, nil
+
+	if err != nil {
+		return nil, fmt.Errorf("fetch id error: %w", err)
+	}
+
+	for i := 0; rs.Next(); i++ {
+		err = rs.Scan(&idList[i])
+
+		if err != nil {
+			return nil, fmt.Errorf("scan id error: %w", err)
+		}
+	}
+
+	return idList, nil
+}
+
+func checkPartitioningSupport(conn DB) error {
+	if _, ok := conn.(*NullDB); ok {
+		return nil
+	}
+
+	var version string
+	err := conn.QueryRow(context.Background(), "SELECT version()").Scan(&version)
+
+	if err != nil {
+		return fmt.Errorf("server version check error: %w", err)
+	}
+
+	if strings.Contains(version, "Redshift") {
+		return fmt.Errorf("declarative partitioning is not supported by Redshift (version=%s)", version)
+	}
+
+	var versionNum int
+	err = conn.QueryRow(context.Background(), "SELECT current_setting('server_version_num')::int").Scan(&versionNum)
+
+	if err != nil {
+		return fmt.Errorf("server version check error: %w", err)
+	}
+
+	// NOTE: Declarative partitioning is available since PostgreSQL 10
+	if versionNum < 100000 {
+		return fmt.Errorf("declarative partitioning requires PostgreSQL 10 or later (server_version_num=%d)", versionNum)
+	}
+
+	return nil
 }
 
 func (task *Task) prePopulateData(ctx context.Context) *errgroup.Group {
 	eg, ctx := errgroup.WithContext(ctx)
 
 	for i := 0; i < task.NAgents; i++ {
+		// NOTE: Not to generate the same rows as the agents
+		seed := task.dataOpts.Seed - int64(i+1)
+		stream := task.NAgents + i
+		cfg := task.RsConfig
+
+		// NOTE: Each agent populates its own database with '--db-per-agent'
+		if task.DBPerAgent {
+			cfg = task.agents[i].rsConfig
+		}
+
 		eg.Go(func() error {
-			data := newData(task.dataOpts, nil)
-			conn, err := task.RsConfig.openAndPing()
+			data := newData(task.dataOpts, nil, seed)
+			data.setSeqStream(stream, 2*task.NAgents)
+			conn, err := cfg.openAndPing(ctx)
 
 			if err != nil {
 				return fmt.Errorf("connection error: %w", err)
 			}
 
-			defer conn.Close(ctx)
+			defer cfg.closeConn(conn)
 
 			for i := 0; i < task.NumberPrePopulatedData; i++ {
 				select {
@@ -245,7 +550,19 @@ func (task *Task) prePopulateData(ctx context.Context) *errgroup.Group {
 }
 
 func (task *Task) Run() (*Recorder, error) {
+	err := task.checkPhase("start")
+
+	if err != nil {
+		return nil, err
+	}
+
 	rec := newRecorder(task.recOpts, task.TaskOpts, task.dataOpts)
+	rec.deferredAgents = task.deferredAgentCount()
+	err = rec.start(task.NAgents * int(math.Max(float64(task.NumberQueriesToExecute), 3)))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to start Recorder: %w", err)
+	}
 
 	defer func() {
 		for _, agent := range task.agents {
@@ -258,21 +575,65 @@ func (task *Task) Run() (*Recorder, error) {
 		rec.close()
 	}()
 
+	prof, err := startProfiler(task.CPUProfile, task.MemProfile)
+
+	if err != nil {
+		return nil, err
+	}
+
 	eg, ctxWithoutCancel := errgroup.WithContext(context.Background())
 	ctx, cancel := context.WithCancel(ctxWithoutCancel)
+	// NOTE: With '--drain', the agents stop issuing queries at the time-out before the running queries are canceled
+	stopCtx, stop := context.WithCancel(ctx)
 	progressTick := time.NewTicker(ProgressReportPeriod * time.Second)
-	rec.start(task.NAgents * int(math.Max(float64(task.NumberQueriesToExecute), 3)))
 	var numTermAgents int32
 
 	// Variables for progress line
 	taskStart := time.Now()
 	prevExecCnt := 0
+	var dash *dashboard
+	var liveHist *liveHistogram
+
+	if task.Dashboard && !task.NoProgress && !task.OnlyPrint {
+		dash = newDashboard()
+
+		if dash == nil {
+			fmt.Fprintf(os.Stderr, "[WARN] stdout is not a terminal, show the progress line instead of the dashboard\n")
+		}
+	} else if task.LiveHistogram && !task.NoProgress && !task.OnlyPrint {
+		liveHist = newLiveHistogram()
+	}
 
 	// Run agents
+	spreadRnd := rand.New(rand.NewSource(task.dataOpts.Seed + StartSpreadSeedOffset))
+
 	for _, v := range task.agents {
 		agent := v
+		startDelay := time.Duration(0)
+
+		if task.RampUp > 0 {
+			startDelay = task.rampUpStartDelay(agent.id)
+		} else if task.StepAgents > 0 {
+			startDelay = task.stepStartDelay(agent.id)
+		}
+
+		// Random jitter to avoid the synchronized first queries
+		// NOTE: Drawn in the order of the agents, so that the same seed gives the same delays
+		if task.StartSpread > 0 {
+			startDelay += time.Duration(spreadRnd.Int63n(int64(task.StartSpread)))
+		}
+
 		eg.Go(func() error {
-			err := agent.run(ctx, rec)
+			if startDelay > 0 {
+				select {
+				case <-stopCtx.Done():
+					atomic.AddInt32(&numTermAgents, 1)
+					return nil
+				case <-time.After(startDelay):
+				}
+			}
+
+			err := agent.run(ctx, stopCtx, rec)
 			atomic.AddInt32(&numTermAgents, 1)
 			return err
 		})
@@ -287,33 +648,112 @@ func (task *Task) Run() (*Recorder, error) {
 				progressTick.Stop()
 				break LOOP
 			case <-progressTick.C:
-				if !task.NoProgress && !task.OnlyPrint {
-					execCnt := rec.Count()
-					termAgentCnt := int(atomic.LoadInt32(&numTermAgents))
-					task.printProgress(execCnt, prevExecCnt, taskStart, termAgentCnt)
-					prevExecCnt = execCnt
+				execCnt := rec.Count()
+				termAgentCnt := int(atomic.LoadInt32(&numTermAgents))
+				rec.updateGauges(task.NAgents-termAgentCnt, float64(execCnt-prevExecCnt)/ProgressReportPeriod)
+
+				if dash != nil {
+					dash.render(task, rec, execCnt, prevExecCnt, taskStart, termAgentCnt)
+				} else if liveHist != nil {
+					liveHist.render(task, rec, execCnt, prevExecCnt, taskStart, termAgentCnt)
+				} else if !task.NoProgress && !task.OnlyPrint {
+					task.printProgress(rec, execCnt, prevExecCnt, taskStart, termAgentCnt)
 				}
+
+				prevExecCnt = execCnt
 			}
 		}
 	}()
 
+	// Client memory guard
+	if task.ClientMemLimit > 0 {
+		go task.watchMemory(ctx, cancel, rec)
+	}
+
+	// Error rate guard
+	if task.MaxErrorRate > 0 {
+		go task.watchErrorRate(ctx, cancel, rec)
+	}
+
+	// Ramp-up end notice
+	if task.RampUp > 0 {
+		go func() {
+			select {
+			case <-ctx.Done():
+				// Nothing to do
+			case <-time.After(task.RampUp):
+				fmt.Fprintf(os.Stderr, "\n[INFO] Ramp-up finished (%s), all agents are running\n", task.RampUp)
+			}
+		}()
+	}
+
+	// Warm-up end notice
+	if task.Warmup > 0 {
+		go func() {
+			select {
+			case <-ctx.Done():
+				// Nothing to do
+			case <-time.After(task.unmeasured()):
+				fmt.Fprintf(os.Stderr, "\n[INFO] Warm-up finished (%s), start measuring\n", task.Warmup)
+			}
+		}()
+	}
+
+	// Warm-up queries end notice
+	if task.WarmupQueries > 0 {
+		go func() {
+			select {
+			case <-ctx.Done():
+				// Nothing to do
+			case <-rec.measuring:
+				fmt.Fprintf(os.Stderr, "\n[INFO] Warm-up finished (%d queries per agent), start measuring\n", task.WarmupQueries)
+			}
+		}()
+	}
+
 	// Time-out processing
-	// NOTE: If it is zero, it will not time out
+	// NOTE: If it is zero, it will not time out. The ramp-up and the warm-up do not count toward the time.
 	if task.Time > 0 {
 		go func() {
+			if task.WarmupQueries > 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-rec.measuring:
+				}
+			}
+
 			select {
 			case <-ctx.Done():
 				// Nothing to do
-			case <-time.After(task.Time):
-				cancel()
+			case <-time.After(time.Until(rec.measureStart()) + task.Time):
+				if !task.Drain {
+					cancel()
+					return
+				}
+
+				stop()
+				fmt.Fprintf(os.Stderr, "\n[INFO] Time is up, waiting for the running queries (timeout=%s)\n", task.DrainTimeout)
+
+				select {
+				case <-ctx.Done():
+					// Nothing to do
+				case <-time.After(task.DrainTimeout):
+					cancel()
+				}
 			}
 		}()
 	}
 
-	task.trapSigint(ctx, cancel, eg)
-	err := eg.Wait()
+	task.trapStopSignals(ctx, stop, cancel, rec)
+	err = eg.Wait()
+	stop()
 	cancel()
 
+	if profErr := prof.stop(); profErr != nil {
+		fmt.Fprintf(os.Stderr, "[WARN] %s\n", profErr)
+	}
+
 	// Clear progress line
 	if !task.NoProgress || !task.OnlyPrint {
 		fmt.Fprintf(os.Stderr, "\r\n\n")
@@ -323,34 +763,92 @@ func (task *Task) Run() (*Recorder, error) {
 		return nil, fmt.Errorf("error during agent running: %w", err)
 	}
 
+	if err := task.checkPhase("end"); err != nil {
+		rec.abort(err.Error())
+	}
+
 	return rec, nil
 }
 
 func (task *Task) Close() error {
+	if task.checkConn != nil {
+		err := task.RsConfig.closeConn(task.checkConn)
+
+		if err != nil {
+			return fmt.Errorf("failed to close DB for phase check: %w", err)
+		}
+	}
+
+	if task.pool != nil {
+		task.pool.close()
+	}
+
+	return nil
+}
+
+// Run the phase check query at a phase boundary and compare its single value with the expected value.
+func (task *Task) checkPhase(phase string) error {
+	if task.checkConn == nil {
+		return nil
+	}
+
+	rows, err := task.checkConn.Query(context.Background(), task.PhaseCheckQuery, pgx.QueryResultFormats{pgx.TextFormatCode})
+
+	if err != nil {
+		return fmt.Errorf("phase check query error (phase=%s, query=%s): %w", phase, task.PhaseCheckQuery, err)
+	}
+
+	if rows == nil {
+		return nil
+	}
+
+	value := "NULL"
+
+	if rows.Next() {
+		if raw := rows.RawValues(); len(raw) > 0 && raw[0] != nil {
+			value = string(raw[0])
+		}
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("phase check query error (phase=%s, query=%s): %w", phase, task.PhaseCheckQuery, err)
+	}
+
+	fmt.Fprintf(os.Stderr, "[INFO] Phase check (phase=%s): %s\n", phase, value)
+
+	if task.PhaseCheckExpect != "" && value != task.PhaseCheckExpect {
+		return fmt.Errorf("phase check mismatch (phase=%s, expected=%s, actual=%s)", phase, task.PhaseCheckExpect, value)
+	}
+
 	return nil
 }
 
 func (task *Task) teardownDB() error {
 	if !task.NoDropDatabase && !task.UseExistingDatabase {
 		newCfg := task.RsConfig.Copy()
-		conn, err := newCfg.openAndPing()
+		conn, err := newCfg.openAndPing(context.Background())
 
 		if err != nil {
 			return fmt.Errorf("connection error: %w", err)
 		}
 
-		defer conn.Close(context.Background())
-		_, err = conn.Exec(context.Background(), fmt.Sprintf(`DROP DATABASE "%s"`, task.RsConfig.Database))
+		defer newCfg.closeConn(conn)
 
-		if err != nil {
-			return fmt.Errorf("drop database error: %w", err)
+		for _, cfg := range task.databases() {
+			_, err = conn.Exec(context.Background(), fmt.Sprintf(`DROP DATABASE "%s"`, cfg.Database))
+
+			if err != nil {
+				return fmt.Errorf("drop database error (database=%s): %w", cfg.Database, err)
+			}
 		}
 	}
 
 	return nil
 }
 
-func (task *Task) printProgress(execCnt int, prevExecCnt int, taskStart time.Time, numTermAgents int) {
+func (task *Task) printProgress(rec *Recorder, execCnt int, prevExecCnt int, taskStart time.Time, numTermAgents int) {
 	qps := float64(execCnt-prevExecCnt) / ProgressReportPeriod
 	elapsedTime := time.Since(taskStart)
 	numRunAgents := task.NAgents - int(numTermAgents)
@@ -364,9 +862,106 @@ func (task *Task) printProgress(execCnt int, prevExecCnt int, taskStart time.Tim
 	min := elapsedTimeRounded / time.Minute
 	sec := (elapsedTimeRounded - min*time.Minute) / time.Second
 	progressLine := fmt.Sprintf("%02d:%02d | %d agents / run %d queries (%.0f qps)", min, sec, numRunAgents, execCnt, qps)
+
+	if phase := task.phase(rec, elapsedTime); phase != "" {
+		progressLine = fmt.Sprintf("%02d:%02d | %s | %d agents / run %d queries (%.0f qps)", min, sec, phase, numRunAgents, execCnt, qps)
+	}
+
 	fmt.Fprintf(os.Stderr, "\r%-*s", termWidth, progressLine)
 }
 
+// Phase of the test at the elapsed time, or empty if there is no ramp-up and no warm-up.
+func (task *Task) phase(rec *Recorder, elapsedTime time.Duration) string {
+	if task.StepAgents > 0 {
+		step := int(elapsedTime / task.StepDuration)
+
+		if step >= task.numSteps() {
+			step = task.numSteps() - 1
+		}
+
+		return fmt.Sprintf("step %d/%d", step+1, task.numSteps())
+	} else if task.RampUp+task.Warmup <= 0 && task.WarmupQueries == 0 {
+		return ""
+	} else if elapsedTime < task.RampUp {
+		return "ramping up"
+	} else if elapsedTime < task.RampUp+task.Warmup || !rec.isMeasuring() {
+		return "warming up"
+	}
+
+	return "measuring"
+}
+
+func (task *Task) watchMemory(ctx context.Context, cancel context.CancelFunc, rec *Recorder) {
+	memTick := time.NewTicker(MemCheckPeriod)
+	defer memTick.Stop()
+	var memStats runtime.MemStats
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-memTick.C:
+			runtime.ReadMemStats(&memStats)
+
+			if memStats.HeapAlloc > task.ClientMemLimit {
+				reason := fmt.Sprintf("client heap (%d bytes) exceeded the limit (%d bytes): connections=%d goroutines=%d",
+					memStats.HeapAlloc, task.ClientMemLimit, task.connCount(), runtime.NumGoroutine())
+				fmt.Fprintf(os.Stderr, "\n[ERROR] Abort: %s\n", reason)
+				rec.abort(reason)
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+func (task *Task) connCount() int {
+	cnt := 0
+
+	// NOTE: The agents of '--pool-size' hold the connections of the pool
+	if task.pool != nil {
+		cnt = task.pool.connCount()
+	}
+
+	for _, agent := range task.agents {
+		if agent.db != nil && agent.pool == nil {
+			cnt++
+		}
+	}
+
+	return cnt
+}
+
+// Stop issuing queries at SIGINT or SIGTERM, and wait for the running queries up to '--drain-timeout'
+// so that the partial results are reported. The second signal cancels the running queries at once.
+func (task *Task) trapStopSignals(ctx context.Context, stop context.CancelFunc, cancel context.CancelFunc, rec *Recorder) {
+	sgnlCh := make(chan os.Signal, 1)
+	signal.Notify(sgnlCh, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		defer signal.Stop(sgnlCh)
+
+		select {
+		case <-ctx.Done():
+			return
+		case sgnl := <-sgnlCh:
+			rec.abort("interrupted by signal: " + sgnl.String())
+		}
+
+		stop()
+		fmt.Fprintf(os.Stderr, "\n[INFO] Interrupted, waiting for the running queries (timeout=%s). Interrupt again to cancel them\n", task.DrainTimeout)
+
+		select {
+		case <-ctx.Done():
+			// Nothing to do
+		case <-sgnlCh:
+			cancel()
+		case <-time.After(task.DrainTimeout):
+			cancel()
+		}
+	}()
+}
+
 func (task *Task) trapSigint(ctx context.Context, cancel context.CancelFunc, eg *errgroup.Group) {
 	// SIGINT
 	sgnlCh := make(chan os.Signal, 1)
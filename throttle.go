@@ -1,23 +1,81 @@
 package rsslap
 
 import (
+	"context"
 	"math"
 	"math/rand"
+	"sync/atomic"
 	"time"
 )
 
 const (
 	ThrottleInterrupt = 1 * time.Millisecond
+	// Unused tokens of '--global-rate' are kept up to this duration
+	GlobalRateBurst = 10 * time.Millisecond
+	// Added to the seed of the agent, not to share the source of the think-time with the data of any agent
+	ThinkTimeSeedOffset = 1 << 32
 )
 
-func loopWithThrottle(rate int, delay int, spread int, proc func(i int) (bool, error)) error {
-	orgLimit := time.Duration(0)
+// Shared rate limit of all agents.
+// NOTE: Each caller reserves the next time slot with a CAS instead of waiting on a mutex
+type globalRateLimiter struct {
+	interval int64
+	next     int64
+}
+
+func newGlobalRateLimiter(rate float64) *globalRateLimiter {
+	return &globalRateLimiter{
+		interval: int64(float64(time.Second) / rate),
+		next:     time.Now().UnixNano(),
+	}
+}
+
+// Wait for the reserved slot. Return the error of the context if canceled.
+// If arrivalRnd is given, the interval to the next slot is drawn from an exponential distribution (Poisson arrival).
+func (limiter *globalRateLimiter) wait(ctx context.Context, arrivalRnd *rand.Rand) error {
+	now := time.Now().UnixNano()
+	interval := limiter.interval
+	var slot int64
+
+	if arrivalRnd != nil {
+		interval = int64(float64(interval) * arrivalRnd.ExpFloat64())
+	}
+
+	for {
+		next := atomic.LoadInt64(&limiter.next)
+		slot = next
+
+		if min := now - int64(GlobalRateBurst); slot < min {
+			slot = min
+		}
+
+		if atomic.CompareAndSwapInt64(&limiter.next, next, slot+interval) {
+			break
+		}
+	}
+
+	if slot <= now {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(time.Duration(slot - now))
+	defer timer.Stop()
 
-	if rate > 0 {
-		// XXX: Add 1 to get closer to the actual rate...
-		orgLimit = time.Second / time.Duration(rate+1)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
+}
 
+// NOTE: If arrivalRnd is given, the rate is an open loop of Poisson arrivals instead
+func loopWithThrottle(rate float64, delay int, spread int, delayDist DelayDistribution, arrivalRnd *rand.Rand, thinkRnd *rand.Rand, proc func(i int) (bool, error)) error {
+	if arrivalRnd != nil && rate > 0 {
+		return loopWithPoissonArrival(rate, arrivalRnd, proc)
+	}
+
+	orgLimit := throttleInterval(rate)
 	thrInt := time.NewTicker(ThrottleInterrupt)
 	defer thrInt.Stop()
 	blockStart := time.Now()
@@ -55,11 +113,63 @@ func loopWithThrottle(rate int, delay int, spread int, proc func(i int) (bool, e
 		if delay == 0 {
 			time.Sleep(currLimit - blockEnd.Sub(blockStart))
 		} else {
-			delayFloat := float64(delay)
-			spreadFloat := float64(spread)
-			randomDelay := math.Max(delayFloat+spreadFloat*(2*rand.NormFloat64()-1), 0)
-			time.Sleep(time.Duration(randomDelay) * time.Second)
+			time.Sleep(thinkTime(thinkRnd, delayDist, delay, spread))
 		}
 		blockStart = time.Now()
 	}
 }
+
+// Return the interval between the queries of the rate, or zero if the rate is not limited.
+// NOTE: The same formula for all rates, so that the rate does not jump at 1 qps;
+// the feedback of the actual interval in loopWithThrottle corrects the overhead of the sleep
+func throttleInterval(rate float64) time.Duration {
+	if rate <= 0 {
+		return 0
+	}
+
+	return time.Duration(float64(time.Second) / rate)
+}
+
+// Draw the delay between the queries of '--delay' (mean) and '--spread' by the distribution:
+// 'uniform' between delay-spread and delay+spread, 'normal' with the stddev of spread, or 'exp' (spread is not used).
+// NOTE: Negative values are clamped to zero
+func thinkTime(rnd *rand.Rand, dist DelayDistribution, delay int, spread int) time.Duration {
+	return drawThinkTime(rnd, dist, time.Duration(delay)*time.Second, time.Duration(spread)*time.Second)
+}
+
+// Draw the think-time of the mean and the spread by the distribution, also for '--statement-interval'.
+func drawThinkTime(rnd *rand.Rand, dist DelayDistribution, mean time.Duration, spread time.Duration) time.Duration {
+	var d float64
+
+	switch dist {
+	case DelayDistNormal:
+		d = float64(mean) + float64(spread)*rnd.NormFloat64()
+	case DelayDistExp:
+		d = float64(mean) * rnd.ExpFloat64()
+	default:
+		d = float64(mean) + float64(spread)*(2*rnd.Float64()-1)
+	}
+
+	return time.Duration(math.Max(d, 0))
+}
+
+// Start the queries at exponentially distributed intervals with the mean of 1/rate.
+// NOTE: The arrivals do not wait for the previous query, so a slow query is followed by the delayed ones at once
+func loopWithPoissonArrival(rate float64, arrivalRnd *rand.Rand, proc func(i int) (bool, error)) error {
+	mean := float64(time.Second) / rate
+	next := time.Now()
+
+	for i := 0; ; i++ {
+		if wait := time.Until(next); wait > 0 {
+			time.Sleep(wait)
+		}
+
+		cont, err := proc(i)
+
+		if !cont || err != nil {
+			return err
+		}
+
+		next = next.Add(time.Duration(arrivalRnd.ExpFloat64() * mean))
+	}
+}
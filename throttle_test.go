@@ -0,0 +1,105 @@
+package rsslap
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestDrawThinkTime(t *testing.T) {
+	const n = 100000
+	mean := 100 * time.Millisecond
+	spread := 20 * time.Millisecond
+
+	tests := []struct {
+		dist       DelayDistribution
+		wantStdDev float64
+	}{
+		{DelayDistUniform, float64(spread) / math.Sqrt(3)},
+		{DelayDistNormal, float64(spread)},
+		{DelayDistExp, float64(mean)},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.dist), func(t *testing.T) {
+			rnd := rand.New(rand.NewSource(1))
+			var sum, sqSum float64
+
+			for i := 0; i < n; i++ {
+				d := float64(drawThinkTime(rnd, tt.dist, mean, spread))
+
+				if d < 0 {
+					t.Fatalf("negative think-time: %s", time.Duration(d))
+				}
+
+				sum += d
+				sqSum += d * d
+			}
+
+			gotMean := sum / n
+			gotStdDev := math.Sqrt(sqSum/n - gotMean*gotMean)
+
+			if math.Abs(gotMean-float64(mean)) > 0.01*float64(mean) {
+				t.Errorf("mean = %s, want %s", time.Duration(gotMean), mean)
+			}
+
+			if math.Abs(gotStdDev-tt.wantStdDev) > 0.02*tt.wantStdDev {
+				t.Errorf("stddev = %s, want %s", time.Duration(gotStdDev), time.Duration(tt.wantStdDev))
+			}
+		})
+	}
+}
+
+func TestDrawThinkTimeClampsNegative(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 10000; i++ {
+		if d := drawThinkTime(rnd, DelayDistNormal, 0, time.Second); d < 0 {
+			t.Fatalf("negative think-time: %s", d)
+		}
+	}
+}
+
+func TestDrawThinkTimeIsSeeded(t *testing.T) {
+	rnd1 := rand.New(rand.NewSource(42))
+	rnd2 := rand.New(rand.NewSource(42))
+
+	for i := 0; i < 100; i++ {
+		d1 := drawThinkTime(rnd1, DelayDistExp, time.Second, 0)
+		d2 := drawThinkTime(rnd2, DelayDistExp, time.Second, 0)
+
+		if d1 != d2 {
+			t.Fatalf("think-time #%d = %s and %s with the same seed", i, d1, d2)
+		}
+	}
+}
+
+// The interval is continuous across 1 qps, where the fractional rates begin.
+func TestThrottleInterval(t *testing.T) {
+	rates := []float64{0.5, 0.99, 0.999, 1, 1.001, 1.01, 2, 100}
+	prev := time.Duration(math.MaxInt64)
+
+	for _, rate := range rates {
+		got := throttleInterval(rate)
+		want := time.Duration(float64(time.Second) / rate)
+
+		if got != want {
+			t.Errorf("throttleInterval(%v) = %s, want %s", rate, got, want)
+		}
+
+		if got >= prev {
+			t.Errorf("throttleInterval(%v) = %s, want less than %s of the lower rate", rate, got, prev)
+		}
+
+		prev = got
+	}
+
+	if below, above := throttleInterval(0.999), throttleInterval(1.001); float64(below-above) > 0.01*float64(time.Second) {
+		t.Errorf("throttleInterval jumps by %s at 1 qps", below-above)
+	}
+
+	if got := throttleInterval(0); got != 0 {
+		t.Errorf("throttleInterval(0) = %s, want 0", got)
+	}
+}
@@ -0,0 +1,135 @@
+package rsslap
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// Agents send their samples every RecordPeriod, so wait for the late ones before writing a second
+	TimeSeriesGracePeriod = 2 * RecordPeriod
+)
+
+var timeSeriesCSVHeader = []string{"elapsed_sec", "queries", "errors", "p50_us", "p99_us"}
+
+// Writes a row of the per-second metrics to a CSV file once each second is complete.
+type timeSeriesCSV struct {
+	file    *os.File
+	w       *csv.Writer
+	seconds map[int]*timeSeriesSecond
+	nextSec int
+}
+
+type timeSeriesSecond struct {
+	resTimes []time.Duration
+	errCnt   int
+}
+
+func openTimeSeriesCSV(path string) (*timeSeriesCSV, error) {
+	f, err := os.Create(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create time series CSV (path=%s): %w", path, err)
+	}
+
+	tc := &timeSeriesCSV{
+		file:    f,
+		w:       csv.NewWriter(f),
+		seconds: map[int]*timeSeriesSecond{},
+	}
+
+	tc.w.Write(timeSeriesCSVHeader)
+	tc.w.Flush()
+
+	if err := tc.w.Error(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write time series CSV (path=%s): %w", path, err)
+	}
+
+	return tc, nil
+}
+
+func (tc *timeSeriesCSV) add(recDps []recorderDataPoint, startedAt time.Time) {
+	for _, v := range recDps {
+		sec := int(v.timestamp.Sub(startedAt) / time.Second)
+
+		if sec < tc.nextSec {
+			// NOTE: Samples of the seconds already written are dropped
+			continue
+		}
+
+		ts, ok := tc.seconds[sec]
+
+		if !ok {
+			ts = &timeSeriesSecond{}
+			tc.seconds[sec] = ts
+		}
+
+		if v.failed {
+			ts.errCnt++
+		} else {
+			ts.resTimes = append(ts.resTimes, v.resTime)
+		}
+	}
+}
+
+// Write the rows of the seconds before 'until'.
+func (tc *timeSeriesCSV) flush(until int) error {
+	for ; tc.nextSec < until; tc.nextSec++ {
+		row := []string{strconv.Itoa(tc.nextSec), "0", "0", "0", "0"}
+
+		if ts, ok := tc.seconds[tc.nextSec]; ok {
+			sort.Slice(ts.resTimes, func(i, j int) bool { return ts.resTimes[i] < ts.resTimes[j] })
+			row[1] = strconv.Itoa(len(ts.resTimes))
+			row[2] = strconv.Itoa(ts.errCnt)
+			row[3] = strconv.FormatInt(percentile(ts.resTimes, 50).Microseconds(), 10)
+			row[4] = strconv.FormatInt(percentile(ts.resTimes, 99).Microseconds(), 10)
+			delete(tc.seconds, tc.nextSec)
+		}
+
+		if err := tc.w.Write(row); err != nil {
+			return err
+		}
+	}
+
+	tc.w.Flush()
+	return tc.w.Error()
+}
+
+func (tc *timeSeriesCSV) close() error {
+	last := -1
+
+	for sec := range tc.seconds {
+		if sec > last {
+			last = sec
+		}
+	}
+
+	err := tc.flush(last + 1)
+
+	if err != nil {
+		tc.file.Close()
+		return err
+	}
+
+	return tc.file.Close()
+}
+
+func (rec *Recorder) writeTimeSeriesCSV(recDps []recorderDataPoint) {
+	if rec.timeSeriesCSV == nil {
+		return
+	}
+
+	rec.timeSeriesCSV.add(recDps, rec.startedAt)
+	until := int(time.Since(rec.startedAt.Add(TimeSeriesGracePeriod)) / time.Second)
+
+	if err := rec.timeSeriesCSV.flush(until); err != nil {
+		fmt.Fprintf(os.Stderr, "\n[WARN] Stop writing time series CSV: %s\n", err)
+		rec.timeSeriesCSV.file.Close()
+		rec.timeSeriesCSV = nil
+	}
+}